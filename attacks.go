@@ -0,0 +1,126 @@
+///////////////////////////////////////////////////////////
+//                                                       //
+//                 Joshua Van Leeuwen                    //
+//                                                       //
+//                University of Bristol                  //
+//                                                       //
+///////////////////////////////////////////////////////////
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"./crt_a"
+	"./fault_a"
+	"./oaep_a"
+	"./pkcs_a"
+	"./power_a"
+	"./report"
+	"./time_a"
+	"./transcript"
+	"./utils"
+)
+
+// Attack run by name, taking the positional arguments in args
+type Subcommand struct {
+	args  []string
+	flags func()
+	main  func(args []string, r *report.Report) os.Error
+}
+
+var (
+	subcommands = map[string]*Subcommand{
+		"oaep":  &Subcommand{[]string{"target", "conf"}, oaep_a.Flags, oaep_a.Main},
+		"time":  &Subcommand{[]string{"target", "conf"}, time_a.Flags, time_a.Main},
+		"fault": &Subcommand{[]string{"target"}, fault_a.Flags, fault_a.Main},
+		"power": &Subcommand{[]string{"target"}, power_a.Flags, power_a.Main},
+		"pkcs":  &Subcommand{[]string{"target", "conf"}, pkcs_a.Flags, pkcs_a.Main},
+		"crt":   &Subcommand{[]string{"target", "conf"}, crt_a.Flags, crt_a.Main},
+	}
+	order = []string{"oaep", "time", "fault", "power", "pkcs", "crt"}
+
+	seed   = flag.Int64("seed", 0, "seed of the random inputs sent to the target (default time based)")
+	format = flag.String("format", "text", "output format of the attack results, text or json")
+	record = flag.String("record", "", "file to record the interactions with the target to (default $"+transcript.RECORD_ENV+")")
+	replay = flag.String("replay", "", "transcript file to replay in place of the target (default $"+transcript.REPLAY_ENV+")")
+)
+
+func main() {
+	if len(os.Args) < 2 || subcommands[os.Args[1]] == nil {
+		usage()
+		os.Exit(2)
+	}
+
+	prog, name := os.Args[0], os.Args[1]
+	sub := subcommands[name]
+
+	// Flags follow the subcommand
+	os.Args = os.Args[1:]
+
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: %s %s [flags]", prog, name)
+		for _, arg := range sub.args {
+			fmt.Fprintf(os.Stderr, " %s", arg)
+		}
+		fmt.Fprintf(os.Stderr, "\n")
+		flag.PrintDefaults()
+	}
+
+	if sub.flags != nil {
+		sub.flags()
+	}
+	flag.Parse()
+
+	if *format != "text" && *format != "json" {
+		utils.Fatal(utils.NewError(fmt.Sprintf("unknown output format '%s'", *format)))
+	}
+
+	if *seed != 0 {
+		utils.Seed(*seed)
+	}
+
+	if *record != "" && *replay != "" {
+		utils.Fatal(utils.NewError("can not both -record and -replay"))
+	}
+	transcript.RecordFile = *record
+	transcript.ReplayFile = *replay
+
+	args, err := utils.ParseArguments(len(sub.args))
+	if err != nil {
+		flag.Usage()
+		utils.Fatal(err)
+	}
+
+	if *format == "text" {
+		if err := sub.main(args, nil); err != nil {
+			utils.Fatal(err)
+		}
+		return
+	}
+
+	// Progress goes to stderr so stdout holds only the report
+	out := os.Stdout
+	os.Stdout = os.Stderr
+
+	r := report.NewReport(name)
+	err = sub.main(args, r)
+	if err := r.Write(out, err); err != nil {
+		utils.Fatal(err)
+	}
+
+	if err != nil {
+		os.Exit(1)
+	}
+}
+
+// Print the subcommands
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: %s <attack> [flags] <target> [conf]\n\nattacks:\n", os.Args[0])
+	for _, name := range order {
+		fmt.Fprintf(os.Stderr, "  %s\n", name)
+	}
+	fmt.Fprintf(os.Stderr, "\nrun '%s <attack> -help' for the flags of an attack\n", os.Args[0])
+}
@@ -0,0 +1,256 @@
+///////////////////////////////////////////////////////////
+//                                                       //
+//                 Joshua Van Leeuwen                    //
+//                                                       //
+//                University of Bristol                  //
+//                                                       //
+///////////////////////////////////////////////////////////
+
+package crt_a
+
+import (
+	"big"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"./crt_c"
+	"./oracle"
+	"./report"
+	"./rsakey"
+	"./transcript"
+	"./utils"
+)
+
+const (
+	WORD_LENGTH = 256
+
+	PAIR   = "pair"
+	SINGLE = "single"
+
+	// Faulty signatures asked for before giving up
+	MAX_FAULTS = 16
+)
+
+var (
+	mode    *string
+	half    *string
+	pemFile *string
+	pkcs8   *bool
+)
+
+type Attack struct {
+	oracle oracle.FaultOracle
+	conf   *crt_c.Conf
+	report *report.Report
+
+	interactions int
+	faults       int
+
+	mode string
+	half []byte
+
+	// PEM file the reconstructed key is written to, if any
+	pemFile string
+	pkcs8   bool
+}
+
+// Register the attack flags
+func Flags() {
+	mode = flag.String("mode", PAIR, "signatures factored, pair for a correct and a faulty signature of one message or single for a faulty signature and its message")
+	half = flag.String("half", "p", "CRT half the target is asked to fault, p or q")
+	pemFile = flag.String("pem", "", "file to write the reconstructed private key to as PEM")
+	pkcs8 = flag.Bool("pkcs8", false, "write the -pem key as PKCS#8 rather than PKCS#1")
+}
+
+// Run the attack on the target and conf file in args, recording the
+// results in r. The target is not used if the conf holds a faulty
+// signature.
+func Main(args []string, r *report.Report) os.Error {
+	fmt.Printf("Initialising attack...")
+	a, err := NewAttack(args, *mode, *half)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("done.\n")
+
+	a.report = r
+	a.pemFile = *pemFile
+	a.pkcs8 = *pkcs8
+	err = a.Run()
+
+	r.Set("faults", a.faults)
+	r.Set("interactions", a.interactions)
+	if a.oracle != nil {
+		r.Set("restarts", a.oracle.Restarts())
+	}
+
+	return err
+}
+
+// Initialise new attack struct, faulting half of each signature for mode
+func NewAttack(args []string, mode, half string) (attack *Attack, err os.Error) {
+	if mode != PAIR && mode != SINGLE {
+		return nil, utils.NewError(fmt.Sprintf("unknown mode '%s', expected %s or %s", mode, PAIR, SINGLE))
+	}
+
+	if half != "p" && half != "q" {
+		return nil, utils.NewError(fmt.Sprintf("unknown CRT half '%s', expected p or q", half))
+	}
+
+	conf, err := crt_c.NewConf(args[1])
+	if err != nil {
+		return nil, err
+	}
+
+	a := &Attack{
+		conf:         conf,
+		interactions: 0,
+		mode:         mode,
+		half:         []byte(half + "\n"),
+	}
+
+	if conf.Offline() {
+		return a, nil
+	}
+
+	cmd, err := transcript.NewProcess(args[0])
+	if err != nil {
+		return nil, err
+	}
+	a.oracle = oracle.NewFaultCommand(cmd)
+
+	return a, nil
+}
+
+// Main attack run function
+func (a *Attack) Run() os.Error {
+	fmt.Printf("Executing Attack.\n")
+
+	now := time.Nanoseconds()
+
+	var p, q *big.Int
+	var err os.Error
+
+	if a.conf.Offline() {
+		fmt.Printf("Factoring N from the conf signatures...")
+		p, q, err = a.offline()
+	} else {
+		// Execute D program
+		if err := a.oracle.Run(); err != nil {
+			return utils.Error("failed to run attack command", err)
+		}
+		defer a.oracle.Kill()
+
+		fmt.Printf("Factoring N from faulty signatures...")
+		p, q, err = a.online()
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Printf("done.\n")
+
+	key, err := rsakey.NewKeyPrimes(a.conf.E, p, q)
+	if err != nil {
+		return utils.Error("failed to reconstruct key", err)
+	}
+	d := key.D
+
+	fmt.Printf("Checking key...")
+	if err := a.checkKey(d); err != nil {
+		return err
+	}
+	fmt.Printf("done.\n")
+
+	fmt.Printf("Attack Complete.\n")
+	fmt.Printf("Elapsed time: %.2fs\n*********\n", float((time.Nanoseconds()-now))/1e9)
+
+	fmt.Printf("P: [%X]\n", p.Bytes())
+	fmt.Printf("Q: [%X]\n", q.Bytes())
+	fmt.Printf("Target material: [%X]\n", d.Bytes())
+	a.report.Set("p", p)
+	a.report.Set("q", q)
+	a.report.Set("material", d)
+	if a.pemFile != "" {
+		// The key is found, so failing to write it is not fatal
+		if err := key.WritePEM(a.pemFile, a.pkcs8); err != nil {
+			fmt.Printf("Warning: %s\n", err.String())
+			a.report.Set("warning", err.String())
+		} else {
+			fmt.Printf("Key: %s\n", a.pemFile)
+			a.report.Set("pem", a.pemFile)
+		}
+	}
+	if a.oracle != nil {
+		fmt.Printf("Faults: %d\n", a.faults)
+		fmt.Printf("Interactions: %d\n", a.interactions)
+		fmt.Printf("Restarts: %d\n", a.oracle.Restarts())
+	}
+
+	return nil
+}
+
+// Factor N from the signatures of the conf
+func (a *Attack) offline() (p, q *big.Int, err os.Error) {
+	if a.conf.S != nil {
+		return a.conf.FactorPair(a.conf.S, a.conf.F)
+	}
+
+	return a.conf.FactorMessage(a.conf.M, a.conf.F)
+}
+
+// Factor N from signatures of random messages, asking again while a fault
+// leaves no factor
+func (a *Attack) online() (p, q *big.Int, err os.Error) {
+	k := int64(len(a.conf.N.Bytes()))
+
+	for a.faults < MAX_FAULTS {
+		// m < N
+		m := utils.RandInt(WORD_LENGTH, k-1)
+
+		var f, s *big.Int
+		if f, err = a.Interact(a.half, m); err != nil {
+			return nil, nil, err
+		}
+		a.faults++
+
+		if a.mode == SINGLE {
+			p, q, err = a.conf.FactorMessage(m, f)
+		} else if s, err = a.Interact([]byte{'\n'}, m); err != nil {
+			return nil, nil, err
+		} else {
+			p, q, err = a.conf.FactorPair(s, f)
+		}
+
+		if err == nil {
+			return p, q, nil
+		}
+	}
+
+	return nil, nil, utils.Error(fmt.Sprintf("no factor of N from %d faulty signatures", a.faults), err)
+}
+
+// Check d inverts e on a random message
+func (a *Attack) checkKey(d *big.Int) os.Error {
+	m := utils.RandInt(WORD_LENGTH, int64(len(a.conf.N.Bytes())-1))
+
+	c := new(big.Int).Exp(m, a.conf.E, a.conf.N)
+	if c.Exp(c, d, a.conf.N).Cmp(m) != 0 {
+		return utils.NewError("recovered private exponent does not invert e")
+	}
+
+	return nil
+}
+
+// Ask D for the signature of m with the fault line given
+func (a *Attack) Interact(fault []byte, m *big.Int) (*big.Int, os.Error) {
+	s, err := a.oracle.Encrypt(fault, m.Bytes())
+	if err != nil {
+		return nil, err
+	}
+
+	a.interactions++
+
+	return new(big.Int).SetBytes(s), nil
+}
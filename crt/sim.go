@@ -0,0 +1,75 @@
+///////////////////////////////////////////////////////////
+//                                                       //
+//                 Joshua Van Leeuwen                    //
+//                                                       //
+//                University of Bristol                  //
+//                                                       //
+///////////////////////////////////////////////////////////
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"./crt_s"
+	"./utils"
+)
+
+var (
+	gen  = flag.Bool("gen", false, "generate a new key and attack conf, then exit")
+	bits = flag.Int("bits", 1024, "modulus size in bits of a generated key")
+	key  = flag.String("key", "", "key file of hex lines N, e, d, p and q (default <binary>.key)")
+	conf = flag.String("conf", "sim.conf", "attack conf file written with -gen")
+	seed = flag.Int64("seed", 0, "seed of the injected faults (default time based)")
+)
+
+func main() {
+	flag.Parse()
+
+	keyFile := *key
+	if keyFile == "" {
+		keyFile = fmt.Sprintf("%s.key", os.Args[0])
+	}
+
+	if *gen {
+		if err := generate(keyFile); err != nil {
+			utils.Fatal(err)
+		}
+		return
+	}
+
+	s, err := crt_s.NewSimulatorFile(keyFile)
+	if err != nil {
+		utils.Fatal(err)
+	}
+
+	if *seed != 0 {
+		s.Seed(*seed)
+	}
+
+	if err := s.Serve(os.Stdin, os.Stdout); err != nil {
+		utils.Fatal(err)
+	}
+}
+
+// Generate a key and an attack conf of its public half
+func generate(keyFile string) os.Error {
+	s, err := crt_s.NewSimulator(*bits)
+	if err != nil {
+		return err
+	}
+
+	if err := s.WriteKey(keyFile); err != nil {
+		return err
+	}
+
+	if err := s.WriteConf(*conf); err != nil {
+		return err
+	}
+
+	fmt.Printf("Key: %s\nConf: %s\nP: [%X]\n", keyFile, *conf, s.P().Bytes())
+
+	return nil
+}
@@ -6,28 +6,34 @@
 //                                                       //
 ///////////////////////////////////////////////////////////
 
-package main
+package fault_a
 
 import (
-	"bytes"
 	"crypto/aes"
-	"encoding/hex"
+	"flag"
 	"fmt"
 	"os"
 	"runtime"
 	"time"
 
-	"./command"
 	"./fault_c"
+	"./oracle"
+	"./report"
+	"./transcript"
 	"./utils"
 )
 
 const (
 	WORD_LENGTH = 16
 	KEY_RANGE   = 256
+
+	// The analysis assumes two rounds remain after the fault
+	FAULT_ROUND = 8
 )
 
 var (
+	round *int
+
 	indexes = [][]int{
 		[]int{0, 7, 10, 13},
 		[]int{1, 4, 11, 14},
@@ -43,7 +49,8 @@ var (
 )
 
 type Attack struct {
-	cmd *command.Command
+	oracle oracle.FaultOracle
+	report *report.Report
 
 	conf *fault_c.Conf
 
@@ -51,41 +58,53 @@ type Attack struct {
 	m_org []byte
 
 	table [][]byte
+	round int
 
 	interactions int
 }
 
-func main() {
+// Register the attack flags
+func Flags() {
+	round = flag.Int("round", FAULT_ROUND, "round the fault is injected before SubBytes of, only 8 is supported")
+}
+
+// Run the attack on the target in args, recording the results in r
+func Main(args []string, r *report.Report) os.Error {
 	fmt.Printf("Initialising attack...")
-	a, err := NewAttack()
+	a, err := NewAttack(args, *round)
 	if err != nil {
-		utils.Fatal(err)
+		return err
 	}
 	fmt.Printf("done.\n")
 
 	runtime.GOMAXPROCS(2)
 
-	if err := a.Run(); err != nil {
-		utils.Fatal(err)
-	}
+	a.report = r
+	err = a.Run()
+
+	r.Set("interactions", a.interactions)
+	r.Set("restarts", a.oracle.Restarts())
+
+	return err
 }
 
-func NewAttack() (*Attack, os.Error) {
-	args, err := utils.ParseArguments()
-	if err != nil {
-		return nil, err
+// Initialise new attack struct, injecting faults before SubBytes of round r
+func NewAttack(args []string, r int) (*Attack, os.Error) {
+	if r != FAULT_ROUND {
+		return nil, utils.NewError(fmt.Sprintf("analysis needs a fault before round %d, round=%d", FAULT_ROUND, r))
 	}
 
-	cmd, err := command.NewCommand(args[0])
+	cmd, err := transcript.NewProcess(args[0])
 	if err != nil {
 		return nil, err
 	}
 
 	return &Attack{
-		cmd: cmd,
+		oracle: oracle.NewFaultCommand(cmd),
 		interactions: 0,
 		conf: fault_c.NewConf(),
 		table: BuildTable(),
+		round: r,
 	},
 		nil
 }
@@ -93,10 +112,10 @@ func NewAttack() (*Attack, os.Error) {
 func (a *Attack) Run() os.Error {
 	fmt.Printf("Executing Attack.\n")
 
-	if err := a.cmd.Run(); err != nil {
+	if err := a.oracle.Run(); err != nil {
 		return err
 	}
-	defer a.cmd.Kill()
+	defer a.oracle.Kill()
 
 	now := time.Nanoseconds()
 
@@ -136,7 +155,9 @@ func (a *Attack) Run() os.Error {
 	fmt.Printf("Elapsed time: %.2fs\n*********\n", float((time.Nanoseconds()-now))/1e9)
 	fmt.Printf("Target material: [%X]\n", d)
 	fmt.Printf("Target material: [%v]\n", d)
-	fmt.Printf("Interactions: %d\n++++++++++++++++++++++++\n", a.interactions)
+	fmt.Printf("Interactions: %d\nRestarts: %d\n++++++++++++++++++++++++\n", a.interactions, a.oracle.Restarts())
+	a.report.Set("material", d)
+	a.report.Set("multi_interactions", a.interactions)
 
 	now = time.Nanoseconds()
 
@@ -154,7 +175,8 @@ func (a *Attack) Run() os.Error {
 	fmt.Printf("Attack Complete.\n")
 	fmt.Printf("Elapsed time: %.2fs\n*********\n", float((time.Nanoseconds()-now))/1e9)
 	fmt.Printf("Target material: [%X]\n", key)
-	fmt.Printf("Interactions: %d\n*********\n", a.interactions)
+	a.report.Set("single_material", key)
+	fmt.Printf("Interactions: %d\nRestarts: %d\n*********\n", a.interactions, a.oracle.Restarts())
 
 	return nil
 }
@@ -162,23 +184,26 @@ func (a *Attack) Run() os.Error {
 func (a *Attack) SinglFaultAttack(c []byte) ([]byte, os.Error) {
 	a.interactions = 1
 
-	f := a.conf.BuildFault(8, 1, 0, 0, 0)
+	f := a.conf.BuildFault(a.round, 1, 0, 0, 0)
 	c2, err := a.Interact(a.m_org, f)
 	if err != nil {
 		return nil, err
 	}
 
 	hs := make([][][]byte, 4)
+	counts := make([]int, 4)
 	var total, checked float64
 
 	for i, index := range indexes {
 		hs[i] = a.gatherHypotheses(c, c2, factors[i], index)
+		counts[i] = len(hs[i])
 		if total == 0 {
 			total = float64(len(hs[i]))
 		} else {
 			total *= float64(len(hs[i]))
 		}
 	}
+	a.report.Set("single_hypotheses", counts)
 
 	fmt.Printf("Possible Keys: [%.0f]\n", total)
 	fmt.Printf("Progress: %.3f%", checked/total)
@@ -215,7 +240,7 @@ func (a *Attack) SinglFaultAttack(c []byte) ([]byte, os.Error) {
 }
 
 func (a *Attack) MultiFaultAttack(c []byte) ([]byte, os.Error) {
-	f := a.conf.BuildFault(8, 1, 0, 0, 0)
+	f := a.conf.BuildFault(a.round, 1, 0, 0, 0)
 	c2, err := a.Interact(a.m_org, f)
 	if err != nil {
 		return nil, err
@@ -227,9 +252,13 @@ func (a *Attack) MultiFaultAttack(c []byte) ([]byte, os.Error) {
 
 	key := make([]byte, WORD_LENGTH)
 
+	// Hypotheses of each column from either fault
+	counts := make([]int, 8)
+
 	for i, index := range indexes {
 		hs1 := a.gatherHypotheses(c, c2, factors[i], index)
 		hs2 := a.gatherHypotheses(c, c3, factors[i], index)
+		counts[2*i], counts[2*i+1] = len(hs1), len(hs2)
 
 	LOOP:
 		for _, h1 := range hs1 {
@@ -243,6 +272,7 @@ func (a *Attack) MultiFaultAttack(c []byte) ([]byte, os.Error) {
 			}
 		}
 	}
+	a.report.Set("hypotheses", counts)
 
 	return key, nil
 }
@@ -316,15 +346,7 @@ func (a *Attack) CheckKey(d []byte) (bool, os.Error) {
 }
 
 func (a *Attack) Interact(message []byte, fault []byte) ([]byte, os.Error) {
-	m := make([]byte, len(message)*2)
-	hex.Encode(m, message)
-	m = bytes.AddByte(m, '\n')
-
-	if err := a.Write(fault, m); err != nil {
-		return nil, err
-	}
-
-	c, err := a.Read()
+	c, err := a.oracle.Encrypt(fault, message)
 	if err != nil {
 		return nil, err
 	}
@@ -334,31 +356,6 @@ func (a *Attack) Interact(message []byte, fault []byte) ([]byte, os.Error) {
 	return c, nil
 }
 
-func (a *Attack) Write(fault, message []byte) os.Error {
-	if err := a.cmd.WriteStdin(fault); err != nil {
-		return utils.Error("failed to write fault", err)
-	}
-	if err := a.cmd.WriteStdin(message); err != nil {
-		return utils.Error("failed to write message", err)
-	}
-
-	return nil
-}
-
-func (a *Attack) Read() ([]byte, os.Error) {
-	c, err := a.cmd.ReadStdout()
-	if err != nil {
-		return nil, utils.Error("failed to read cipher text", err)
-	}
-
-	i, err := utils.BytesToInt(utils.TrimLeft(c))
-	if err != nil {
-		return nil, utils.Error("failed to convert cipher text to int", err)
-	}
-
-	return i.Bytes(), err
-}
-
 func (a *Attack) sameBytes(k1 []byte, k2 []byte) bool {
 	if len(k1) != len(k2) {
 		return false
@@ -0,0 +1,57 @@
+///////////////////////////////////////////////////////////
+//                                                       //
+//                 Joshua Van Leeuwen                    //
+//                                                       //
+//                University of Bristol                  //
+//                                                       //
+///////////////////////////////////////////////////////////
+
+package main
+
+import (
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"os"
+
+	"./fault_s"
+	"./utils"
+)
+
+var (
+	key  = flag.String("key", "", "hex AES-128 cipher key (default random)")
+	seed = flag.Int64("seed", 0, "seed of the injected faults (default time based)")
+)
+
+func main() {
+	flag.Parse()
+
+	s, err := newSimulator()
+	if err != nil {
+		utils.Fatal(err)
+	}
+
+	if *seed != 0 {
+		s.Seed(*seed)
+	}
+
+	fmt.Fprintf(os.Stderr, "Key: [%X]\n", s.Key())
+
+	if err := s.Serve(os.Stdin, os.Stdout); err != nil {
+		utils.Fatal(err)
+	}
+}
+
+// Initialise the simulator with the key flag if given
+func newSimulator() (*fault_s.Simulator, os.Error) {
+	if *key == "" {
+		return fault_s.NewSimulator()
+	}
+
+	k := make([]byte, len(*key)/2)
+	if _, err := hex.Decode(k, []byte(*key)); err != nil {
+		return nil, utils.Error("failed to decode key", err)
+	}
+
+	return fault_s.NewSimulatorKey(k)
+}
@@ -0,0 +1,1279 @@
+///////////////////////////////////////////////////////////
+//                                                       //
+//                 Joshua Van Leeuwen                    //
+//                                                       //
+//                University of Bristol                  //
+//                                                       //
+///////////////////////////////////////////////////////////
+
+package oaep_a
+
+import (
+	"big"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"os"
+	"rand"
+	"time"
+
+	"./oaep_c"
+	"./oracle"
+	"./pkcs1"
+	"./pool"
+	"./report"
+	"./transcript"
+	"./utils"
+)
+
+const (
+	SUCCESS = '0'
+	ERROR1  = '1'
+	ERROR2  = '2'
+
+	WORD_LENGTH = 256
+	BASE        = 16
+
+	// Contradictions resolved before a noisy oracle is given up on
+	MAX_BACKTRACKS = 64
+
+	// Share of calibration ciphertexts a latency threshold must classify
+	MIN_ACCURACY = 0.9
+
+	// Values of i tried for each balanced step of an optimised findEM
+	BALANCE_CANDIDATES = 32
+)
+
+var (
+	hash        *string
+	mgf         *string
+	votes       *int
+	latency     *bool
+	reps        *int
+	calibration *int
+	sign        *string
+	raw         *bool
+	optimise    *bool
+	poolSize    *int
+)
+
+type Attack struct {
+	oracle oracle.DecryptOracle
+	pool   *pool.Pool
+	conf   *oaep_c.Conf
+	report *report.Report
+
+	// Oracle of each copy of the target in the pool
+	oracles []oracle.DecryptOracle
+
+	// Pool F2 windows are queried on concurrently, nil to query in turn
+	candidates *pool.Pool
+
+	// Progress is not printed while ciphertexts are attacked together
+	quiet bool
+
+	interactions int
+	votes        int
+	backtracks   int
+
+	// Blinding factor taking c s0^e under B, drawn from rnd
+	s0  *big.Int
+	rnd *rand.Rand
+
+	// Set when forging a signature of digest rather than decrypting
+	digest []byte
+	raw    bool
+
+	// Tile F2 windows, balance findEM splits and trim intervals to the
+	// bounds on m found so far
+	optimise bool
+	m_lo     *big.Int
+	m_hi     *big.Int
+
+	// Blinded message answering queries offline, to count those of a run
+	known *big.Int
+
+	// Set when ERROR1 and ERROR2 are told apart by latency
+	latency     bool
+	reps        int
+	calibration int
+	accuracy    float64
+
+	// Results of the attack on a single ciphertext
+	f1          *big.Int
+	f2          *big.Int
+	em          *big.Int
+	material    []byte
+	unoptimised int
+}
+
+// A query of findEM, the interval before it and the response
+type step struct {
+	f3 *big.Int
+	i  *big.Int
+
+	m_min *big.Int
+	m_max *big.Int
+
+	code    byte
+	checked bool
+
+	// Keep the narrowed interval within the one before
+	trim bool
+}
+
+// Register the attack flags
+func Flags() {
+	hash = flag.String("hash", "", "hash of the label and seed, one of sha1, sha224, sha256, sha384 or sha512 (default conf hash)")
+	mgf = flag.String("mgf", "", "hash of MGF1 (default -hash if given, else conf mgf)")
+	votes = flag.Int("votes", 1, "responses out of 2n-1 that must agree against a noisy target, 1 trusts every response")
+	latency = flag.Bool("latency", false, "tell ERROR1 from ERROR2 by response time, for targets giving the same code for both")
+	reps = flag.Int("reps", 5, "queries timed for each latency response")
+	calibration = flag.Int("calibration", 32, "ciphertexts of each of ERROR1 and ERROR2 timed to calibrate -latency")
+	sign = flag.String("sign", "", "hex SHA-1 or SHA-2 digest to forge a PKCS#1 v1.5 signature of instead of decrypting the conf ciphertext")
+	raw = flag.Bool("raw", false, "forge a raw RSA signature of the -sign hex as an integer")
+	optimise = flag.Bool("optimise", false, "tile the F2 search and balance findEM splits to save queries, reporting those the unoptimised attack needs of a reliable target. With -pool and one ciphertext, F2 windows are queried concurrently; findEM queries stay in turn as each splits the interval the last left")
+	poolSize = flag.Int("pool", 1, "number of copies of the target to decrypt the conf ciphertexts on concurrently, or to query F2 windows on with -optimise")
+}
+
+// Run the attack on the target and conf file in args, recording the
+// results in r
+func Main(args []string, r *report.Report) os.Error {
+	fmt.Printf("Initialising attack...")
+	a, err := NewAttack(args, *hash, *mgf, *poolSize)
+	if err != nil {
+		return err
+	}
+
+	if err := a.SetVotes(*votes); err != nil {
+		return err
+	}
+
+	if *latency {
+		if err := a.SetLatency(*reps, *calibration); err != nil {
+			return err
+		}
+	}
+
+	if *sign != "" {
+		if err := a.SetSign(*sign, *raw); err != nil {
+			return err
+		}
+	}
+
+	a.SetOptimise(*optimise)
+	fmt.Printf("done.\n")
+
+	a.SetReport(r)
+	err = a.Run()
+
+	r.Set("votes", a.votes)
+	r.Set("backtracks", a.backtracks)
+	r.Set("interactions", a.interactions)
+	r.Set("restarts", a.pool.Restarts())
+
+	return err
+}
+
+// Initialise new attack struct over n copies of the target, overriding
+// the conf hashes h and mgf if given
+func NewAttack(args []string, h, mgf string, n int) (attack *Attack, err os.Error) {
+	if n < 1 {
+		return nil, utils.NewError(fmt.Sprintf("expected at least 1 target, got pool=%d", n))
+	}
+
+	conf, err := oaep_c.NewConf(args[1])
+	if err != nil {
+		return nil, err
+	}
+
+	if err := conf.SetHash(h, mgf); err != nil {
+		return nil, err
+	}
+
+	cmds, err := transcript.NewProcesses(args[0], n)
+	if err != nil {
+		return nil, err
+	}
+
+	oracles := make([]oracle.DecryptOracle, n)
+	for i := range oracles {
+		oracles[i] = oracle.NewDecryptCommand(cmds[i], WORD_LENGTH)
+	}
+
+	return NewAttackOracles(conf, oracles), nil
+}
+
+// Initialise new attack struct on conf over at least one oracle, each of
+// a separate copy of the target
+func NewAttackOracles(conf *oaep_c.Conf, oracles []oracle.DecryptOracle) *Attack {
+	return &Attack{
+		conf:         conf,
+		interactions: 0,
+		votes:        1,
+		reps:         1,
+		s0:           big.NewInt(1),
+		oracle:       oracles[0],
+		oracles:      oracles,
+		pool:         newPool(oracles),
+	}
+}
+
+// Pool over the oracles
+func newPool(oracles []oracle.DecryptOracle) *pool.Pool {
+	targets := make([]oracle.Target, len(oracles))
+	for i := range targets {
+		targets[i] = oracles[i]
+	}
+
+	return pool.NewPool(targets)
+}
+
+// Set the responses out of 2n-1 that must agree on each query. Over one,
+// decisions are also confirmed and contradictions backtracked.
+func (a *Attack) SetVotes(n int) os.Error {
+	if n < 1 {
+		return utils.NewError(fmt.Sprintf("expected at least 1 vote, got %d", n))
+	}
+
+	a.votes = n
+
+	return nil
+}
+
+// Tile the F2 search, balance findEM splits and trim intervals to save
+// queries, counting those the unoptimised attack would make
+func (a *Attack) SetOptimise(on bool) { a.optimise = on }
+
+// Record the results of the run in r
+func (a *Attack) SetReport(r *report.Report) { a.report = r }
+
+// Tell ERROR1 from ERROR2 by the median latency of reps queries, with a
+// threshold calibrated on n known ciphertexts of each
+func (a *Attack) SetLatency(reps, n int) os.Error {
+	if reps < 1 || n < 1 {
+		return utils.NewError(fmt.Sprintf("expected at least 1 rep and calibration ciphertext, got %d and %d", reps, n))
+	}
+
+	for i := range a.oracles {
+		a.oracles[i] = oracle.NewLatencyOracle(a.oracles[i], reps)
+	}
+
+	a.oracle = a.oracles[0]
+	a.pool = newPool(a.oracles)
+	a.latency = true
+	a.reps = reps
+	a.calibration = n
+
+	return nil
+}
+
+// Forge a signature of the hex digest h in place of decrypting the conf
+// ciphertext. The private key operation on the encoded digest is found
+// as the decryption of a blinded ciphertext. Raw signatures sign h as an
+// integer, others are RSASSA-PKCS1-v1_5.
+func (a *Attack) SetSign(h string, raw bool) os.Error {
+	if len(a.conf.Ciphertexts) > 1 {
+		return utils.NewError(fmt.Sprintf("expected a conf of 1 ciphertext to sign with, got %d", len(a.conf.Ciphertexts)))
+	}
+
+	if len(h)%2 != 0 {
+		return utils.NewError("odd length hex digest")
+	}
+
+	digest := make([]byte, len(h)/2)
+	if _, err := hex.Decode(digest, []byte(h)); err != nil {
+		return utils.Error("failed to decode digest", err)
+	}
+
+	mu := pkcs1.OS2IP(digest)
+	if !raw {
+		EM, err := pkcs1.EncodeSignature(digest, len(a.conf.N.Bytes()))
+		if err != nil {
+			return err
+		}
+		mu = pkcs1.OS2IP(EM)
+	}
+
+	if mu.Cmp(a.conf.N) >= 0 {
+		return utils.NewError("digest too large for N")
+	}
+
+	// m^e = C gives m = mu^d
+	a.conf.C = mu
+	a.conf.Ciphertexts[0].C = mu
+	a.digest = digest
+	a.raw = raw
+
+	return nil
+}
+
+// Main attack run function
+func (a *Attack) Run() os.Error {
+	fmt.Printf("Executing Attack.\n")
+
+	// Execute D programs
+	if err := a.pool.Run(); err != nil {
+		return utils.Error("failed to run attack command", err)
+	}
+
+	now := time.Nanoseconds()
+
+	if a.latency {
+		fmt.Printf("Calibrating latency...")
+		for _, o := range a.oracles {
+			if err := a.calibrate(o.(*oracle.LatencyOracle)); err != nil {
+				return err
+			}
+		}
+		fmt.Printf("done.\n")
+	}
+
+	n := len(a.conf.Ciphertexts)
+	if n > 1 {
+		fmt.Printf("Decrypting %d ciphertexts over %d targets.\n", n, a.pool.Len())
+	}
+
+	jobs := make([]*Attack, n)
+	for i := range jobs {
+		jobs[i] = a.target(i)
+	}
+
+	job := func(o oracle.Target, i int) os.Error {
+		b := jobs[i]
+		b.oracle = o.(oracle.DecryptOracle)
+
+		if err := b.decrypt(); err != nil && b.quiet {
+			return utils.Error(fmt.Sprintf("ciphertext %d", i+1), err)
+		} else if err != nil {
+			return err
+		}
+
+		if b.quiet {
+			fmt.Printf("Ciphertext %d decrypted after %d interactions.\n", i+1, b.interactions)
+		}
+
+		return nil
+	}
+
+	var err os.Error
+	if a.pool.Len() == 1 {
+		// In order, so a transcript of the target replays
+		for i := range jobs {
+			if e := job(a.oracle, i); e != nil && err == nil {
+				err = e
+			}
+		}
+	} else if n == 1 && a.optimise {
+		// A single ciphertext has the pool to itself for F2 windows
+		jobs[0].candidates = a.pool
+		err = job(a.oracle, 0)
+	} else {
+		err = a.pool.Do(n, job)
+	}
+
+	// Kill D programs
+	if e := a.pool.Kill(); e != nil && err == nil {
+		err = e
+	}
+
+	baseline := 0
+	for _, b := range jobs {
+		a.interactions += b.interactions
+		a.backtracks += b.backtracks
+		baseline += b.unoptimised
+	}
+
+	if a.optimise {
+		a.report.Set("baseline_interactions", baseline)
+	}
+
+	if n > 1 {
+		a.results(jobs)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Attack Complete.\n")
+	if n == 1 {
+		b := jobs[0]
+		fmt.Printf("S0: [%s]\n", b.s0.String())
+		fmt.Printf("F1: [%s]\n", b.f1.String())
+		fmt.Printf("F2: [%s]\n", b.f2.String())
+		fmt.Printf("EM: [%s]\n", b.em.String())
+	}
+	fmt.Printf("Elapsed time: %.2fs\n*********\n", float((time.Nanoseconds()-now))/1e9)
+
+	if n == 1 {
+		fmt.Printf("Target material: [%X]\n", jobs[0].material)
+		a.report.Set("material", jobs[0].material)
+	} else {
+		for i, b := range jobs {
+			fmt.Printf("Target material %d: [%X]\n", i+1, b.material)
+		}
+	}
+
+	if a.noisy() {
+		fmt.Printf("Backtracks: %d\n", a.backtracks)
+	}
+	fmt.Printf("Interactions: %d\n", a.interactions)
+	if a.optimise {
+		fmt.Printf("Baseline interactions: %d\n", baseline)
+	}
+	fmt.Printf("Restarts: %d\n", a.pool.Restarts())
+
+	return nil
+}
+
+// Attack on ciphertext i of the conf, with the settings of a. Progress
+// and results of a batch are not reported by each attack. Each has its
+// own random source as attacks of a pool run concurrently.
+func (a *Attack) target(i int) *Attack {
+	b := &Attack{
+		oracle:       a.oracle,
+		conf:         a.conf.Select(i),
+		interactions: 0,
+		votes:        a.votes,
+		reps:         a.reps,
+		s0:           big.NewInt(1),
+		rnd:          utils.NewRand(),
+		digest:       a.digest,
+		raw:          a.raw,
+		optimise:     a.optimise,
+	}
+
+	if len(a.conf.Ciphertexts) == 1 {
+		b.report = a.report
+	} else {
+		b.quiet = true
+	}
+
+	return b
+}
+
+// Report the message and material of each ciphertext of a batch, nil for
+// those that failed
+func (a *Attack) results(jobs []*Attack) {
+	ems := make([]*big.Int, len(jobs))
+	materials := make([][]byte, len(jobs))
+
+	for i, b := range jobs {
+		ems[i] = b.em
+		materials[i] = b.material
+	}
+
+	a.report.Set("ciphertexts", len(jobs))
+	a.report.Set("ems", ems)
+	a.report.Set("materials", materials)
+}
+
+// Decrypt the conf ciphertext, or forge the signature of the digest
+func (a *Attack) decrypt() os.Error {
+	a.progress("Blinding...")
+	if err := a.blind(); err != nil {
+		return err
+	}
+	a.progress("done.\n")
+	a.report.Set("s0", a.s0)
+
+	pre := a.interactions
+
+	a.progress("Finding F1...")
+	f1, err := a.findF1()
+	if err != nil {
+		return err
+	}
+	a.progress("done.\n")
+	a.report.Set("f1", f1)
+
+	a.progress("Finding F2...")
+	f2, err := a.searchF2(f1)
+	if err != nil {
+		return err
+	}
+	a.progress("done.\n")
+	a.report.Set("f2", f2)
+
+	a.progress("Finding EM...")
+	em, err := a.findEM(f2)
+	if err != nil {
+		return err
+	}
+	a.progress("done.\n")
+
+	if a.optimise {
+		if a.unoptimised, err = a.baseline(em, pre); err != nil {
+			return err
+		}
+	}
+
+	em = a.unblind(em)
+	a.report.Set("em", em)
+
+	a.progress("Checking EM...")
+	if err := a.conf.CheckMessage(em); err != nil {
+		return utils.Error("target responses inconsistent with N and e, try -votes or -latency", err)
+	}
+	a.progress("done.\n")
+
+	var M []byte
+	if a.digest != nil {
+		a.progress("Verifying signature...")
+		if M, err = a.signature(em); err != nil {
+			return err
+		}
+		a.progress("done.\n")
+	} else {
+		a.progress("Decoding EM...")
+		if M, err = a.decode(em); err != nil {
+			return err
+		}
+	}
+
+	a.f1, a.f2, a.em, a.material = f1, f2, em, M
+
+	return nil
+}
+
+// Print progress unless quiet
+func (a *Attack) progress(s string) {
+	if !a.quiet {
+		fmt.Print(s)
+	}
+}
+
+// Find s0 taking c s0^e under B, as Bleichenbacher's blinding, so any
+// ciphertext can be attacked. Ciphertexts of OAEP encodings keep s0 = 1.
+func (a *Attack) blind() os.Error {
+	one := big.NewInt(1)
+	k := int64(len(a.conf.N.Bytes()))
+
+	for {
+		code, err := a.query(one, a.votes)
+		if err != nil {
+			return err
+		}
+
+		if code == ERROR2 {
+			if !a.noisy() {
+				return nil
+			}
+
+			ok, err := a.confirm(one, ERROR2)
+			if err != nil || ok {
+				return err
+			}
+		}
+
+		a.s0 = utils.RandIntFrom(a.rnd, WORD_LENGTH, k)
+		a.s0.Mod(a.s0, a.conf.N)
+		for a.s0.Cmp(big.NewInt(2)) < 0 {
+			a.s0.Add(a.s0, big.NewInt(2))
+		}
+	}
+
+	return nil
+}
+
+// Message of the target ciphertext from that of the blinded one,
+// em s0^-1 mod N
+func (a *Attack) unblind(em *big.Int) *big.Int {
+	m := new(big.Int).Mul(em, utils.ModInverse(a.s0, a.conf.N))
+	return m.Mod(m, a.conf.N)
+}
+
+// Find F1, the first power of two taking f m over B
+func (a *Attack) findF1() (*big.Int, os.Error) {
+	f1 := big.NewInt(2)
+	two := big.NewInt(2)
+
+	// m >= 1 so f1 m >= B by 2B
+	limit := new(big.Int).Mul(a.conf.B, two)
+
+	for {
+		code, err := a.query(f1, a.votes)
+		if err != nil {
+			return nil, err
+		}
+
+		if code != ERROR1 {
+			if f1.Mul(f1, two).Cmp(limit) > 0 {
+				return nil, utils.NewError("no ERROR1 response for any f1 m, try -latency")
+			}
+			continue
+		}
+
+		if !a.noisy() {
+			return f1, nil
+		}
+
+		// Confirm f1 m >= B and f1/2 m < B, stepping back over a false ERROR2
+		ok, err := a.confirm(f1, ERROR1)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			f1.Mul(f1, two)
+			continue
+		}
+
+		half, _ := new(big.Int).Div(f1, two)
+		if half.Cmp(two) < 0 {
+			return f1, nil
+		}
+
+		if ok, err = a.confirm(half, ERROR2); err != nil || ok {
+			return f1, err
+		}
+
+		if err := a.backtrack(); err != nil {
+			return nil, err
+		}
+		f1 = half
+	}
+
+	return f1, nil
+}
+
+// Find F2 by tiling when optimised, else as Manger
+func (a *Attack) searchF2(f1 *big.Int) (*big.Int, os.Error) {
+	if a.optimise {
+		return a.findF2Tiled(f1)
+	}
+
+	return a.findF2(f1)
+}
+
+// Find F2 with windows [N, N+B) of f2 m that tile the interval F1 leaves
+// m in, rather than stepping f2 by f1/2 over windows that overlap by up
+// to half. Each ERROR1 lowers the upper bound on m below the last window,
+// so the windows after one are known before its response and as many as
+// there are candidate targets are queried at once.
+func (a *Attack) findF2Tiled(f1 *big.Int) (*big.Int, os.Error) {
+	one := big.NewInt(1)
+
+	// f1 m >= B and f1/2 m < B
+	lo := utils.CeilingDiv(a.conf.B, f1)
+	top := new(big.Int).Lsh(a.conf.B, 1)
+	top.Sub(top, one)
+	top, _ = top.Div(top, f1)
+
+	NB := new(big.Int).Add(a.conf.N, a.conf.B)
+	NB.Sub(NB, one)
+
+	hi := new(big.Int).Set(top)
+
+	for {
+		if hi.Cmp(lo) < 0 {
+			if !a.noisy() {
+				return nil, utils.NewError("no F2 left in the interval of F1")
+			}
+
+			if err := a.backtrack(); err != nil {
+				return nil, err
+			}
+			hi.Set(top)
+		}
+
+		// Windows from hi down, each queried in case those above give ERROR1
+		var fs, his []*big.Int
+		for h := hi; len(fs) < a.width() && h.Cmp(lo) >= 0; {
+			// Largest f2 with f2 h < N + B
+			f2, _ := new(big.Int).Div(NB, h)
+			fs = utils.AppendBigInt(fs, f2)
+			his = utils.AppendBigInt(his, h)
+
+			// f2 m < N
+			h = utils.CeilingDiv(a.conf.N, f2)
+			h.Sub(h, one)
+		}
+
+		codes, err := a.queryAll(fs)
+		if err != nil {
+			return nil, err
+		}
+
+		for j, f2 := range fs {
+			code := codes[j]
+
+			if code == ERROR2 && a.noisy() {
+				if ok, err := a.confirm(f2, ERROR2); err != nil {
+					return nil, err
+				} else if !ok {
+					code = ERROR1
+				}
+			}
+
+			if code == ERROR2 {
+				a.m_lo = lo
+				a.m_hi = his[j]
+				return f2, nil
+			}
+		}
+
+		hi = utils.CeilingDiv(a.conf.N, fs[len(fs)-1])
+		hi.Sub(hi, one)
+	}
+
+	return nil, nil
+}
+
+// Find F2, the first multiple of F1/2 taking f m under B after wrapping N
+func (a *Attack) findF2(f1 *big.Int) (*big.Int, os.Error) {
+	f1_half := new(big.Int)
+	f2 := new(big.Int)
+	f1_half, _ = f1_half.Div(f1, big.NewInt(2))
+
+	f2.Add(a.conf.N, a.conf.B)
+	f2, _ = f2.Div(f2, a.conf.B)
+	f2.Mul(f2, f1_half)
+
+	start := new(big.Int).Set(f2)
+
+	for {
+		code, err := a.query(f2, a.votes)
+		if err != nil {
+			return nil, err
+		}
+
+		if code != ERROR2 {
+			f2.Add(f2, f1_half)
+			continue
+		}
+
+		if !a.noisy() {
+			return f2, nil
+		}
+
+		// Confirm f2 m < B and the multiple before it >= B, stepping back
+		// over a false ERROR1
+		ok, err := a.confirm(f2, ERROR2)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			f2.Add(f2, f1_half)
+			continue
+		}
+
+		prev := new(big.Int).Sub(f2, f1_half)
+		if prev.Cmp(start) < 0 {
+			return f2, nil
+		}
+
+		if ok, err = a.confirm(prev, ERROR1); err != nil || ok {
+			return f2, err
+		}
+
+		if err := a.backtrack(); err != nil {
+			return nil, err
+		}
+		f2 = prev
+	}
+
+	return f2, nil
+}
+
+// Find encoded message. Against a noisy oracle, an empty interval or one
+// converging on the wrong message is a contradiction, resolved by
+// rechecking the steps taken and resuming from the first one found wrong.
+func (a *Attack) findEM(f2 *big.Int) (*big.Int, os.Error) {
+	m_min, m_max := a.interval(f2)
+
+	var steps []*step
+
+	for {
+		switch m_min.Cmp(m_max) {
+		case 0:
+			if !a.noisy() || a.conf.CheckMessage(a.unblind(m_min)) == nil {
+				return m_min, nil
+			}
+
+		case 1:
+			if !a.noisy() {
+				return nil, utils.NewError(fmt.Sprintf("m_min larger than m_max: %s : %s, try -votes", m_min.String(), m_max.String()))
+			}
+
+		default:
+			st := a.nextStep(m_min, m_max)
+
+			code, err := a.query(st.f3, a.votes)
+			if err != nil {
+				return nil, err
+			}
+
+			st.code = code
+			m_min, m_max = st.narrow(a.conf)
+			steps = appendStep(steps, st)
+			continue
+		}
+
+		if err := a.backtrack(); err != nil {
+			return nil, err
+		}
+
+		var err os.Error
+		if steps, err = a.recheck(steps); err != nil {
+			return nil, err
+		}
+
+		if len(steps) == 0 {
+			// Every step held, start over
+			m_min, m_max = a.interval(f2)
+			continue
+		}
+
+		m_min, m_max = steps[len(steps)-1].narrow(a.conf)
+	}
+
+	return m_min, nil
+}
+
+// Interval of m once f2 m is in [N, N+B), trimmed to the bounds found by
+// the F2 search when optimised
+func (a *Attack) interval(f2 *big.Int) (m_min, m_max *big.Int) {
+	m_min = utils.CeilingDiv(a.conf.N, f2)
+
+	m_max = new(big.Int).Add(a.conf.N, a.conf.B)
+	m_max, _ = m_max.Div(m_max, f2)
+
+	if a.m_lo != nil {
+		m_min = max(m_min, a.m_lo)
+		m_max = min(m_max, a.m_hi)
+	}
+
+	return m_min, m_max
+}
+
+// Choose the next f3 to split the interval [m_min, m_max]
+func (a *Attack) nextStep(m_min, m_max *big.Int) *step {
+	if a.optimise {
+		if st := a.balancedStep(m_min, m_max); st != nil {
+			return st
+		}
+	}
+
+	f_tmp := new(big.Int)
+	tmp := new(big.Int)
+	i := new(big.Int)
+	f3 := new(big.Int)
+	two := big.NewInt(2)
+
+	f_tmp.Mul(a.conf.B, two)
+	tmp.Sub(m_max, m_min)
+	f_tmp, _ = f_tmp.Div(f_tmp, tmp)
+
+	i.Mul(f_tmp, m_min)
+	i, _ = i.Div(i, a.conf.N)
+
+	f3.Mul(i, a.conf.N)
+	f3 = utils.CeilingDiv(f3, m_min)
+
+	return &step{
+		f3:    f3,
+		i:     i,
+		m_min: new(big.Int).Set(m_min),
+		m_max: new(big.Int).Set(m_max),
+		trim:  a.optimise,
+	}
+}
+
+// Choose i and f3 putting the boundary iN + B of f3 m nearest the middle
+// of [m_min, m_max], so each query halves the interval. Manger's f3 only
+// places the bottom of the interval at iN, which can leave the boundary
+// far off the middle. Returns nil if no candidate straddles the interval.
+func (a *Attack) balancedStep(m_min, m_max *big.Int) *step {
+	N := a.conf.N
+	B := a.conf.B
+	one := big.NewInt(1)
+
+	w := new(big.Int).Sub(m_max, m_min)
+	w.Add(w, one)
+
+	mid := new(big.Int).Add(m_min, m_max)
+	mid.Rsh(mid, 1)
+
+	// i <= (2B mid / w - B) / N keeps f3 w / 2 within B
+	i := new(big.Int).Lsh(B, 1)
+	i.Mul(i, mid)
+	i, _ = i.Div(i, w)
+	i.Sub(i, B)
+	i, _ = i.Div(i, N)
+
+	var best *step
+	var score *big.Int
+
+	for n := 0; n < BALANCE_CANDIDATES && i.Sign() > 0; n++ {
+		iNB := new(big.Int).Mul(i, N)
+		iNB.Add(iNB, B)
+
+		f, _ := new(big.Int).Div(iNB, mid)
+
+		for _, f3 := range []*big.Int{f, new(big.Int).Add(f, one)} {
+			if !a.straddles(i, f3, m_min, m_max) {
+				continue
+			}
+
+			// |2p - w| for the p values of m under the boundary
+			p := utils.CeilingDiv(iNB, f3)
+			p.Sub(p, m_min)
+			p.Lsh(p, 1)
+			p.Sub(p, w)
+			p.Abs(p)
+
+			if best == nil || p.Cmp(score) < 0 {
+				best = &step{
+					f3:    f3,
+					i:     new(big.Int).Set(i),
+					m_min: new(big.Int).Set(m_min),
+					m_max: new(big.Int).Set(m_max),
+					trim:  true,
+				}
+				score = p
+			}
+		}
+
+		i = new(big.Int).Sub(i, one)
+	}
+
+	return best
+}
+
+// Whether f m stays within [iN, (i+1)N) for m in [m_min, m_max], with
+// the boundary iN + B inside
+func (a *Attack) straddles(i, f, m_min, m_max *big.Int) bool {
+	lo := new(big.Int).Mul(f, m_min)
+	hi := new(big.Int).Mul(f, m_max)
+
+	iN := new(big.Int).Mul(i, a.conf.N)
+	iNB := new(big.Int).Add(iN, a.conf.B)
+	next := new(big.Int).Add(iN, a.conf.N)
+
+	return lo.Cmp(iN) >= 0 && hi.Cmp(next) < 0 && lo.Cmp(iNB) <= 0 && hi.Cmp(iNB) >= 0
+}
+
+// Recheck steps from the latest with more votes, dropping those that hold
+// until one is found wrong. The wrong step is corrected and kept as the
+// last; no steps are left if every one held. Steps are rechecked once.
+func (a *Attack) recheck(steps []*step) ([]*step, os.Error) {
+	for len(steps) > 0 {
+		st := steps[len(steps)-1]
+
+		if !st.checked {
+			code, err := a.query(st.f3, 2*a.votes)
+			if err != nil {
+				return nil, err
+			}
+
+			st.checked = true
+			if code != st.code {
+				st.code = code
+				return steps, nil
+			}
+		}
+
+		steps = steps[0 : len(steps)-1]
+	}
+
+	return steps, nil
+}
+
+// Interval of m after the response to st
+func (st *step) narrow(conf *oaep_c.Conf) (m_min, m_max *big.Int) {
+	m_min = new(big.Int).Set(st.m_min)
+	m_max = new(big.Int).Set(st.m_max)
+
+	switch st.code {
+	case ERROR1:
+		m_min.Mul(st.i, conf.N)
+		m_min.Add(m_min, conf.B)
+		m_min = utils.CeilingDiv(m_min, st.f3)
+
+	case ERROR2:
+		m_max.Mul(st.i, conf.N)
+		m_max.Add(m_max, conf.B)
+		m_max, _ = m_max.Div(m_max, st.f3)
+	}
+
+	if st.trim {
+		m_min = max(m_min, st.m_min)
+		m_max = min(m_max, st.m_max)
+	}
+
+	return m_min, m_max
+}
+
+func appendStep(slice []*step, elem *step) []*step {
+	if len(slice) < cap(slice) {
+		slice = slice[0 : len(slice)+1]
+		slice[len(slice)-1] = elem
+		return slice
+	}
+
+	fresh := make([]*step, len(slice)+1, cap(slice)*2+1)
+	copy(fresh, slice)
+	fresh[len(slice)] = elem
+	return fresh
+}
+
+// Count the queries the unoptimised attack makes for the blinded message
+// em, after the pre made before F1
+func (a *Attack) baseline(em *big.Int, pre int) (int, os.Error) {
+	b := &Attack{
+		conf:         a.conf,
+		interactions: pre,
+		votes:        1,
+		reps:         1,
+		s0:           a.s0,
+		known:        em,
+	}
+
+	f1, err := b.findF1()
+	if err != nil {
+		return 0, err
+	}
+
+	f2, err := b.findF2(f1)
+	if err != nil {
+		return 0, err
+	}
+
+	if _, err := b.findEM(f2); err != nil {
+		return 0, err
+	}
+
+	return b.interactions, nil
+}
+
+// Query D on f until n responses agree whether f m is over B, out of at
+// most 2n - 1. m is blinded by s0.
+func (a *Attack) query(f *big.Int, n int) (byte, os.Error) {
+	if a.known != nil {
+		a.interactions++
+
+		z := new(big.Int).Mul(f, a.known)
+		if z.Mod(z, a.conf.N).Cmp(a.conf.B) >= 0 {
+			return ERROR1, nil
+		}
+		return ERROR2, nil
+	}
+
+	fs := new(big.Int).Mul(f, a.s0)
+	c := a.conf.RSAf(fs.Mod(fs, a.conf.N))
+	ones, twos := 0, 0
+
+	for ones < n && twos < n {
+		code, err := a.Interact(c)
+		if err != nil {
+			return 0, err
+		}
+
+		switch code {
+		case ERROR1:
+			ones++
+		case ERROR2, SUCCESS:
+			twos++
+		default:
+			return 0, utils.NewError(fmt.Sprintf("got unexpected code from D: %c", code))
+		}
+	}
+
+	if ones == n {
+		return ERROR1, nil
+	}
+
+	return ERROR2, nil
+}
+
+// Query each of fs, concurrently over the candidate pool if there is one.
+// Each query is made on a copy of a so the counts can be summed after.
+func (a *Attack) queryAll(fs []*big.Int) ([]byte, os.Error) {
+	codes := make([]byte, len(fs))
+
+	if a.candidates == nil {
+		for i, f := range fs {
+			code, err := a.query(f, a.votes)
+			if err != nil {
+				return nil, err
+			}
+			codes[i] = code
+		}
+
+		return codes, nil
+	}
+
+	counts := make([]int, len(fs))
+	err := a.candidates.Do(len(fs), func(t oracle.Target, i int) os.Error {
+		b := *a
+		b.oracle = t.(oracle.DecryptOracle)
+		b.interactions = 0
+
+		code, err := b.query(fs[i], a.votes)
+		codes[i] = code
+		counts[i] = b.interactions
+
+		return err
+	})
+
+	for _, n := range counts {
+		a.interactions += n
+	}
+
+	return codes, err
+}
+
+// Number of queries made at once
+func (a *Attack) width() int {
+	if a.candidates == nil {
+		return 1
+	}
+
+	return a.candidates.Len()
+}
+
+// Whether f gives code when queried with twice the votes
+func (a *Attack) confirm(f *big.Int, code byte) (bool, os.Error) {
+	got, err := a.query(f, 2*a.votes)
+	return got == code, err
+}
+
+// Count a contradiction, giving up once there are too many
+func (a *Attack) backtrack() os.Error {
+	a.backtracks++
+
+	if a.backtracks > MAX_BACKTRACKS {
+		return utils.NewError(fmt.Sprintf("gave up after %d contradictions, oracle too noisy for %d votes", MAX_BACKTRACKS, a.votes))
+	}
+
+	return nil
+}
+
+func min(x, y *big.Int) *big.Int {
+	if x.Cmp(y) < 0 {
+		return x
+	}
+	return y
+}
+
+func max(x, y *big.Int) *big.Int {
+	if x.Cmp(y) > 0 {
+		return x
+	}
+	return y
+}
+
+// Whether responses are voted on rather than trusted
+func (a *Attack) noisy() bool { return a.votes > 1 }
+
+// Time ciphertexts of messages over and under B, giving ERROR1 and ERROR2,
+// to set the latency threshold of o. The least accurate calibration of
+// the pool is reported.
+func (a *Attack) calibrate(o *oracle.LatencyOracle) os.Error {
+	k := int64(len(a.conf.N.Bytes()))
+	NB := new(big.Int).Sub(a.conf.N, a.conf.B)
+
+	c1 := make([]*big.Int, a.calibration)
+	c2 := make([]*big.Int, a.calibration)
+
+	for i := range c1 {
+		// B <= x < N
+		x := utils.RandInt(WORD_LENGTH, k)
+		x.Mod(x, NB)
+		x.Add(x, a.conf.B)
+		c1[i] = new(big.Int).Exp(x, a.conf.E, a.conf.N)
+
+		// 0 <= x < B
+		x = utils.RandInt(WORD_LENGTH, k-1)
+		c2[i] = new(big.Int).Exp(x, a.conf.E, a.conf.N)
+	}
+
+	t1, t2, accuracy, err := o.Calibrate(a.conf.L, c1, c2)
+	a.interactions += 2 * a.calibration * a.reps
+	if err != nil {
+		return err
+	}
+
+	if a.accuracy == 0 || accuracy < a.accuracy {
+		a.accuracy = accuracy
+		a.report.Set("latency_error1", t1)
+		a.report.Set("latency_error2", t2)
+		a.report.Set("latency_accuracy", accuracy)
+	}
+
+	if accuracy < MIN_ACCURACY {
+		return utils.NewError(fmt.Sprintf("latencies of ERROR1 (%.0fns) and ERROR2 (%.0fns) not separable, %.0f%% of calibration classified", t1, t2, 100*accuracy))
+	}
+
+	return nil
+}
+
+// Decode the OAEP message of em, or give em whole when the ciphertext was
+// blinded and not of an OAEP encoding
+func (a *Attack) decode(em *big.Int) ([]byte, os.Error) {
+	M, err := a.EME_OAEP_Decode(em)
+	if err != nil && a.s0.Cmp(big.NewInt(1)) == 0 {
+		return nil, utils.Error("decoding error", err)
+	}
+
+	if err != nil {
+		a.progress("not an OAEP encoding, reporting the message whole.\n")
+		return pkcs1.I2OSP(em, len(a.conf.N.Bytes()))
+	}
+
+	return M, nil
+}
+
+// Signature octets of s = mu^d, verified against N and e
+func (a *Attack) signature(s *big.Int) ([]byte, os.Error) {
+	S, err := pkcs1.I2OSP(s, len(a.conf.N.Bytes()))
+	if err != nil {
+		return nil, err
+	}
+
+	if a.raw {
+		// CheckMessage already holds s^e = mu
+		return S, nil
+	}
+
+	if err := pkcs1.VerifySignature(a.conf.N, a.conf.E, a.digest, S); err != nil {
+		return nil, err
+	}
+
+	return S, nil
+}
+
+// Decode the encoded message. Also checks the label hashed is equal to DB
+func (a *Attack) EME_OAEP_Decode(em *big.Int) ([]byte, os.Error) {
+	k := len(a.conf.N.Bytes())
+
+	EM, err := pkcs1.I2OSP(em, k)
+	if err != nil {
+		return nil, err
+	}
+
+	M, err := pkcs1.Decode(a.conf.Params(a.conf.Label), k, EM)
+	if err != nil {
+		return nil, err
+	}
+	a.progress("done.\n")
+
+	return M, nil
+}
+
+// Interact with D through the decryption oracle
+func (a *Attack) Interact(c *big.Int) (res byte, err os.Error) {
+	res, err = a.oracle.Decrypt(a.conf.L, c)
+	if err != nil {
+		return 0, err
+	}
+
+	a.interactions += a.reps
+
+	if res > ERROR2 {
+		return 0, utils.NewError(fmt.Sprintf("got bad error code from D: %s", string(res)))
+	}
+
+	return res, nil
+}
@@ -0,0 +1,131 @@
+///////////////////////////////////////////////////////////
+//                                                       //
+//                 Joshua Van Leeuwen                    //
+//                                                       //
+//                University of Bristol                  //
+//                                                       //
+///////////////////////////////////////////////////////////
+
+package main
+
+import (
+	"big"
+	"crypto/rand"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"./oaep_c"
+	"./oaep_s"
+	"./utils"
+)
+
+const (
+	LABEL_LENGTH   = 16
+	MESSAGE_LENGTH = 26
+)
+
+var (
+	gen        = flag.Bool("gen", false, "generate a new key and challenge conf, then exit")
+	bits       = flag.Int("bits", 1024, "modulus size in bits of a generated key")
+	key        = flag.String("key", "", "key file of hex lines N, e and d (default <binary>.key)")
+	conf       = flag.String("conf", "sim.conf", "challenge conf file written with -gen")
+	challenges = flag.Int("challenges", 1, "ciphertexts of the challenge conf, each of a random message and label")
+	hash       = flag.String("hash", oaep_c.SHA1, "hash of the label and seed, one of sha1, sha224, sha256, sha384 or sha512")
+	mgf        = flag.String("mgf", "", "hash of MGF1 (default -hash)")
+	noise      = flag.Float64("noise", 0, "probability of swapping the ERROR1 and ERROR2 responses")
+	seed       = flag.Int64("seed", 0, "seed of the response noise (default time based)")
+	delay      = flag.Int64("delay", 0, "nanoseconds added to decoding, leaking ERROR2 through response time")
+	uniform    = flag.Bool("uniform", false, "answer ERROR2 for both errors")
+)
+
+func main() {
+	flag.Parse()
+
+	keyFile := *key
+	if keyFile == "" {
+		keyFile = fmt.Sprintf("%s.key", os.Args[0])
+	}
+
+	if *gen {
+		if err := generate(keyFile); err != nil {
+			utils.Fatal(err)
+		}
+		return
+	}
+
+	s, err := oaep_s.NewSimulatorFile(keyFile)
+	if err != nil {
+		utils.Fatal(err)
+	}
+
+	if err := s.SetHash(*hash, *mgf); err != nil {
+		utils.Fatal(err)
+	}
+
+	s.Noise = *noise
+	s.Delay = *delay
+	s.Uniform = *uniform
+	if *seed != 0 {
+		s.Seed(*seed)
+	}
+
+	if err := s.Serve(os.Stdin, os.Stdout); err != nil {
+		utils.Fatal(err)
+	}
+}
+
+// Generate a key and a challenge conf of random messages and labels
+func generate(keyFile string) os.Error {
+	if *challenges < 1 {
+		return utils.NewError(fmt.Sprintf("expected at least 1 challenge, got %d", *challenges))
+	}
+
+	s, err := oaep_s.NewSimulator(*bits)
+	if err != nil {
+		return err
+	}
+
+	if err := s.SetHash(*hash, *mgf); err != nil {
+		return err
+	}
+
+	ls := make([][]byte, *challenges)
+	ms := make([][]byte, *challenges)
+	cs := make([]*big.Int, *challenges)
+
+	for i := range cs {
+		ls[i] = make([]byte, LABEL_LENGTH)
+		ms[i] = make([]byte, MESSAGE_LENGTH)
+		if _, err := io.ReadFull(rand.Reader, ls[i]); err != nil {
+			return utils.Error("failed to generate label", err)
+		}
+		if _, err := io.ReadFull(rand.Reader, ms[i]); err != nil {
+			return utils.Error("failed to generate message", err)
+		}
+
+		if cs[i], err = s.Challenge(ms[i], ls[i]); err != nil {
+			return err
+		}
+	}
+
+	if err := s.WriteKey(keyFile); err != nil {
+		return err
+	}
+
+	if err := s.WriteConf(*conf, ls, cs); err != nil {
+		return err
+	}
+
+	fmt.Printf("Key: %s\nConf: %s\n", keyFile, *conf)
+	if *challenges == 1 {
+		fmt.Printf("Message: [%X]\n", ms[0])
+	} else {
+		for i, m := range ms {
+			fmt.Printf("Message %d: [%X]\n", i+1, m)
+		}
+	}
+
+	return nil
+}
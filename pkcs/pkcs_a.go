@@ -0,0 +1,422 @@
+///////////////////////////////////////////////////////////
+//                                                       //
+//                 Joshua Van Leeuwen                    //
+//                                                       //
+//                University of Bristol                  //
+//                                                       //
+///////////////////////////////////////////////////////////
+
+package pkcs_a
+
+import (
+	"big"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"./oracle"
+	"./pkcs1"
+	"./pkcs_c"
+	"./report"
+	"./transcript"
+	"./utils"
+)
+
+const (
+	WORD_LENGTH = 256
+
+	STRICT  = "strict"
+	LENIENT = "lenient"
+)
+
+var (
+	mode *string
+)
+
+type Attack struct {
+	oracle oracle.PaddingOracle
+	conf   *pkcs_c.Conf
+	report *report.Report
+
+	interactions int
+	iterations   int
+
+	// Smallest and largest conforming encodings
+	lo *big.Int
+	hi *big.Int
+}
+
+// Interval [a, b] of candidate encodings
+type interval struct {
+	a *big.Int
+	b *big.Int
+}
+
+// Register the attack flags
+func Flags() {
+	mode = flag.String("oracle", LENIENT, "checks made by the target, strict for the whole encoding or lenient for only its leading 00 02")
+}
+
+// Run the attack on the target and conf file in args, recording the
+// results in r
+func Main(args []string, r *report.Report) os.Error {
+	fmt.Printf("Initialising attack...")
+	a, err := NewAttack(args, *mode)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("done.\n")
+
+	a.report = r
+	err = a.Run()
+
+	r.Set("iterations", a.iterations)
+	r.Set("interactions", a.interactions)
+	r.Set("restarts", a.oracle.Restarts())
+
+	return err
+}
+
+// Initialise new attack struct against a strict or lenient oracle
+func NewAttack(args []string, mode string) (attack *Attack, err os.Error) {
+	if mode != STRICT && mode != LENIENT {
+		return nil, utils.NewError(fmt.Sprintf("unknown oracle '%s', expected %s or %s", mode, STRICT, LENIENT))
+	}
+
+	conf, err := pkcs_c.NewConf(args[1])
+	if err != nil {
+		return nil, err
+	}
+
+	cmd, err := transcript.NewProcess(args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	lo, hi := bounds(conf, mode == STRICT)
+
+	return &Attack{
+			conf:         conf,
+			interactions: 0,
+			oracle:       oracle.NewPaddingCommand(cmd, WORD_LENGTH),
+			lo:           lo,
+			hi:           hi,
+		},
+		nil
+}
+
+// Bounds of a conforming encoding. Any 00 02 prefix passes a lenient
+// oracle, a strict one also needs PS_MIN non zero padding octets and a
+// zero separator.
+func bounds(conf *pkcs_c.Conf, strict bool) (lo, hi *big.Int) {
+	two := big.NewInt(2)
+	three := big.NewInt(3)
+
+	lo = new(big.Int).Mul(two, conf.B)
+	hi = new(big.Int).Mul(three, conf.B)
+
+	if !strict {
+		return lo, hi.Sub(hi, big.NewInt(1))
+	}
+
+	// 00 02 01 .. 01 00 .. 00 up to 00 02 FF .. FF 00
+	k := len(conf.N.Bytes())
+	for i := 2; i < pkcs1.PS_MIN+2; i++ {
+		lo.Add(lo, new(big.Int).Exp(big.NewInt(WORD_LENGTH), big.NewInt(int64(k-1-i)), nil))
+	}
+
+	return lo, hi.Sub(hi, big.NewInt(WORD_LENGTH))
+}
+
+// Main attack run function
+func (a *Attack) Run() os.Error {
+	fmt.Printf("Executing Attack.\n")
+
+	// Execute D program
+	if err := a.oracle.Run(); err != nil {
+		return utils.Error("failed to run attack command", err)
+	}
+
+	now := time.Nanoseconds()
+
+	fmt.Printf("Blinding...")
+	s0, err := a.blind()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("done.\n")
+	a.report.Set("s0", s0)
+
+	fmt.Printf("Narrowing intervals...")
+	m, err := a.search(s0)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("done.\n")
+
+	// Kill D program
+	if err := a.oracle.Kill(); err != nil {
+		return err
+	}
+
+	// m0 = m s0^-1 mod N
+	m.Mul(m, utils.ModInverse(s0, a.conf.N))
+	m.Mod(m, a.conf.N)
+
+	fmt.Printf("Checking message...")
+	if err := a.conf.CheckMessage(m); err != nil {
+		return err
+	}
+	fmt.Printf("done.\n")
+
+	k := len(a.conf.N.Bytes())
+	EM, err := pkcs1.I2OSP(m, k)
+	if err != nil {
+		return err
+	}
+
+	// A ciphertext that was never padded still gives its plain message
+	M, err := pkcs1.DecodeV15(k, EM)
+	if err != nil {
+		fmt.Printf("Message is not a conforming encoding, reporting it whole.\n")
+		M = EM
+	}
+
+	fmt.Printf("Attack Complete.\n")
+	fmt.Printf("S0: [%s]\n", s0.String())
+	fmt.Printf("EM: [%s]\n", m.String())
+	fmt.Printf("Elapsed time: %.2fs\n*********\n", float((time.Nanoseconds()-now))/1e9)
+
+	fmt.Printf("Target material: [%X]\n", M)
+	a.report.Set("material", M)
+	fmt.Printf("Iterations: %d\n", a.iterations)
+	fmt.Printf("Interactions: %d\n", a.interactions)
+	fmt.Printf("Restarts: %d\n", a.oracle.Restarts())
+
+	return nil
+}
+
+// Step 1, find s0 such that c s0^e is conforming
+func (a *Attack) blind() (*big.Int, os.Error) {
+	s0 := big.NewInt(1)
+	k := int64(len(a.conf.N.Bytes()))
+
+	for {
+		ok, err := a.Interact(a.conf.RSAf(s0))
+		if err != nil {
+			return nil, err
+		}
+
+		if ok {
+			return s0, nil
+		}
+
+		s0 = utils.RandInt(WORD_LENGTH, k)
+		s0.Mod(s0, a.conf.N)
+		for s0.Cmp(big.NewInt(2)) < 0 {
+			s0.Add(s0, big.NewInt(2))
+		}
+	}
+
+	return s0, nil
+}
+
+// Steps 2 to 4, narrow the intervals holding m s0 mod N down to a single
+// encoding
+func (a *Attack) search(s0 *big.Int) (*big.Int, os.Error) {
+	N := a.conf.N
+	one := big.NewInt(1)
+
+	M := []*interval{&interval{new(big.Int).Set(a.lo), new(big.Int).Set(a.hi)}}
+
+	// Step 2a, smallest s from N/3B
+	s := utils.CeilingDiv(N, new(big.Int).Mul(big.NewInt(3), a.conf.B))
+	if err := a.nextS(s0, s); err != nil {
+		return nil, err
+	}
+
+	for {
+		a.iterations++
+
+		M = a.narrow(M, s)
+		if len(M) == 0 {
+			return nil, utils.NewError("no intervals left, oracle is not consistent with its mode")
+		}
+
+		if len(M) == 1 && M[0].a.Cmp(M[0].b) == 0 {
+			break
+		}
+
+		if len(M) > 1 {
+			// Step 2b, search on from the last s
+			s.Add(s, one)
+			if err := a.nextS(s0, s); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		// Step 2c, a single interval left
+		var err os.Error
+		if s, err = a.searchInterval(s0, s, M[0]); err != nil {
+			return nil, err
+		}
+	}
+
+	// Step 4, m s0 mod N
+	return M[0].a, nil
+}
+
+// Increment s until c (s0 s)^e is conforming
+func (a *Attack) nextS(s0, s *big.Int) os.Error {
+	one := big.NewInt(1)
+	c := a.conf.RSAf(s0)
+
+	for {
+		z := new(big.Int).Exp(s, a.conf.E, a.conf.N)
+		z.Mul(z, c)
+		z.Mod(z, a.conf.N)
+
+		ok, err := a.Interact(z)
+		if err != nil {
+			return err
+		}
+
+		if ok {
+			return nil
+		}
+
+		s.Add(s, one)
+	}
+
+	return nil
+}
+
+// Search for s through r with the single interval I left
+func (a *Attack) searchInterval(s0, s *big.Int, I *interval) (*big.Int, os.Error) {
+	N := a.conf.N
+	one := big.NewInt(1)
+	c := a.conf.RSAf(s0)
+
+	// r >= 2 (b s - lo) / N
+	r := new(big.Int).Mul(I.b, s)
+	r.Sub(r, a.lo)
+	r.Mul(r, big.NewInt(2))
+	r = utils.CeilingDiv(r, N)
+
+	for ; ; r.Add(r, one) {
+		rN := new(big.Int).Mul(r, N)
+
+		// (lo + rN) / b <= s <= (hi + rN) / a
+		sMin := utils.CeilingDiv(new(big.Int).Add(a.lo, rN), I.b)
+		sMax := new(big.Int).Add(a.hi, rN)
+		sMax, _ = sMax.Div(sMax, I.a)
+
+		for s := sMin; s.Cmp(sMax) <= 0; s.Add(s, one) {
+			z := new(big.Int).Exp(s, a.conf.E, N)
+			z.Mul(z, c)
+			z.Mod(z, N)
+
+			ok, err := a.Interact(z)
+			if err != nil {
+				return nil, err
+			}
+
+			if ok {
+				return s, nil
+			}
+		}
+	}
+
+	return nil, nil
+}
+
+// Step 3, narrow each interval by the conforming s
+func (a *Attack) narrow(M []*interval, s *big.Int) []*interval {
+	N := a.conf.N
+	one := big.NewInt(1)
+
+	var next []*interval
+
+	for _, I := range M {
+		// (a s - hi) / N <= r <= (b s - lo) / N
+		rMin := new(big.Int).Mul(I.a, s)
+		rMin.Sub(rMin, a.hi)
+		if rMin.Sign() < 0 {
+			rMin.SetInt64(0)
+		}
+		rMin = utils.CeilingDiv(rMin, N)
+
+		rMax := new(big.Int).Mul(I.b, s)
+		rMax.Sub(rMax, a.lo)
+		rMax, _ = rMax.Div(rMax, N)
+
+		for r := rMin; r.Cmp(rMax) <= 0; r.Add(r, one) {
+			rN := new(big.Int).Mul(r, N)
+
+			lo := utils.CeilingDiv(new(big.Int).Add(a.lo, rN), s)
+			if lo.Cmp(I.a) < 0 {
+				lo.Set(I.a)
+			}
+
+			hi := new(big.Int).Add(a.hi, rN)
+			hi, _ = hi.Div(hi, s)
+			if hi.Cmp(I.b) > 0 {
+				hi.Set(I.b)
+			}
+
+			if lo.Cmp(hi) <= 0 {
+				next = union(next, &interval{lo, hi})
+			}
+		}
+	}
+
+	return next
+}
+
+// Add I to M, merging it with any intervals it overlaps
+func union(M []*interval, I *interval) []*interval {
+	next := make([]*interval, 0, len(M)+1)
+
+	for _, J := range M {
+		if J.b.Cmp(I.a) < 0 || J.a.Cmp(I.b) > 0 {
+			next = next[0 : len(next)+1]
+			next[len(next)-1] = J
+			continue
+		}
+
+		I = &interval{min(I.a, J.a), max(I.b, J.b)}
+	}
+
+	next = next[0 : len(next)+1]
+	next[len(next)-1] = I
+
+	return next
+}
+
+func min(x, y *big.Int) *big.Int {
+	if x.Cmp(y) < 0 {
+		return x
+	}
+	return y
+}
+
+func max(x, y *big.Int) *big.Int {
+	if x.Cmp(y) > 0 {
+		return x
+	}
+	return y
+}
+
+// Query D through the padding oracle
+func (a *Attack) Interact(c *big.Int) (bool, os.Error) {
+	ok, err := a.oracle.Conforming(c)
+	if err != nil {
+		return false, err
+	}
+
+	a.interactions++
+
+	return ok, nil
+}
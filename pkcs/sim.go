@@ -0,0 +1,88 @@
+///////////////////////////////////////////////////////////
+//                                                       //
+//                 Joshua Van Leeuwen                    //
+//                                                       //
+//                University of Bristol                  //
+//                                                       //
+///////////////////////////////////////////////////////////
+
+package main
+
+import (
+	"crypto/rand"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"./pkcs_s"
+	"./utils"
+)
+
+const (
+	MESSAGE_LENGTH = 26
+)
+
+var (
+	gen     = flag.Bool("gen", false, "generate a new key and challenge conf, then exit")
+	bits    = flag.Int("bits", 1024, "modulus size in bits of a generated key")
+	key     = flag.String("key", "", "key file of hex lines N, e and d (default <binary>.key)")
+	conf    = flag.String("conf", "sim.conf", "challenge conf file written with -gen")
+	lenient = flag.Bool("lenient", false, "only check the leading 00 02 of the encoding")
+)
+
+func main() {
+	flag.Parse()
+
+	keyFile := *key
+	if keyFile == "" {
+		keyFile = fmt.Sprintf("%s.key", os.Args[0])
+	}
+
+	if *gen {
+		if err := generate(keyFile); err != nil {
+			utils.Fatal(err)
+		}
+		return
+	}
+
+	s, err := pkcs_s.NewSimulatorFile(keyFile)
+	if err != nil {
+		utils.Fatal(err)
+	}
+	s.SetStrict(!*lenient)
+
+	if err := s.Serve(os.Stdin, os.Stdout); err != nil {
+		utils.Fatal(err)
+	}
+}
+
+// Generate a key and a challenge conf for a random message
+func generate(keyFile string) os.Error {
+	s, err := pkcs_s.NewSimulator(*bits)
+	if err != nil {
+		return err
+	}
+
+	m := make([]byte, MESSAGE_LENGTH)
+	if _, err := io.ReadFull(rand.Reader, m); err != nil {
+		return utils.Error("failed to generate message", err)
+	}
+
+	c, err := s.Challenge(m)
+	if err != nil {
+		return err
+	}
+
+	if err := s.WriteKey(keyFile); err != nil {
+		return err
+	}
+
+	if err := s.WriteConf(*conf, c); err != nil {
+		return err
+	}
+
+	fmt.Printf("Key: %s\nConf: %s\nMessage: [%X]\n", keyFile, *conf, m)
+
+	return nil
+}
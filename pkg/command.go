@@ -9,44 +9,122 @@
 package command
 
 import (
+	"bufio"
 	"fmt"
 	"exec"
 	"os"
+	"syscall"
+	"time"
 
 	"./utils"
 )
 
+const (
+	NEW_LINE         = '\n'
+	DEFAULT_TIMEOUT  = 10e9
+	DEFAULT_RESTARTS = 3
+)
+
+// Process the oracles talk to, either a running Command or a stand in.
+// ReadLines returns the n lines of one response without their new lines.
+type Process interface {
+	Run() os.Error
+	WriteStdin(b []byte) os.Error
+	ReadLines(n int) ([][]byte, os.Error)
+	Kill() os.Error
+	Restarts() int
+}
+
+// Command running a target binary. Reads of stdout are buffered and framed
+// by delimiter, failing once Timeout nanoseconds pass without a response.
+// A command that exits or hangs is run again up to MaxRestarts times per
+// interaction and the interaction in progress is sent again.
 type Command struct {
-	cmd  *exec.Cmd
-	file string
+	cmd    *exec.Cmd
+	file   string
+	args   []string
+	reader *bufio.Reader
+
+	Timeout  int64
+	timedOut bool
+
+	MaxRestarts int
+	restarts    int
+	attempts    int
+	warmUp      []byte
+	warmUpLines int
+	pending     [][]byte
+}
+
+// Result of a read on stdout
+type response struct {
+	b   [][]byte
+	err os.Error
 }
 
 // Initlaise new Command struct
 func NewCommand(file string) (*Command, os.Error) {
+	return NewCommandArgs(file, nil)
+}
+
+// Initialise new Command struct, passing args to the executable
+func NewCommandArgs(file string, args []string) (*Command, os.Error) {
 	if _, err := exec.LookPath(file); err != nil {
 		return nil, utils.Error(fmt.Sprintf("error looking up binary file '%s'", file), err)
 	}
 
+	argv := []string{file}
+	for _, arg := range args {
+		argv = utils.Append(argv, arg)
+	}
+
 	return &Command{
-		file: file,
+		file:        file,
+		args:        argv,
+		Timeout:     DEFAULT_TIMEOUT,
+		MaxRestarts: DEFAULT_RESTARTS,
 	},
 		nil
 }
 
 // Run the contained executable file
 func (c *Command) Run() os.Error {
-	cmd, err := exec.Run(c.file, []string{}, nil, exec.Pipe, exec.Pipe, exec.Pipe)
+	cmd, err := exec.Run(c.file, c.args, nil, exec.Pipe, exec.Pipe, exec.Pipe)
 	if err != nil {
 		return utils.Error(fmt.Sprintf("error running command '%s'", c.file), err)
 	}
 
 	c.cmd = cmd
+	c.reader = bufio.NewReader(cmd.Stdout)
+	c.timedOut = false
 
 	return nil
 }
 
-// Write bytes to command stdin
+// Set bytes written to the command after every restart, and the number
+// of lines it answers them with
+func (c *Command) SetWarmUp(b []byte, lines int) {
+	c.warmUp = b
+	c.warmUpLines = lines
+}
+
+// Number of times the command has been run again over its life
+func (c *Command) Restarts() int { return c.restarts }
+
+// Write bytes to command stdin. They are kept until the response is read
+// so they can be sent again on restart.
 func (c *Command) WriteStdin(b []byte) os.Error {
+	c.pending = utils.AppendByte2(c.pending, b)
+
+	if err := c.write(b); err != nil {
+		return c.restart(err)
+	}
+
+	return nil
+}
+
+// Write bytes to the running command stdin
+func (c *Command) write(b []byte) os.Error {
 	if _, err := c.cmd.Stdin.Write(b); err != nil {
 		return utils.Error("error writing bytes to command stdin", err)
 	}
@@ -54,15 +132,177 @@ func (c *Command) WriteStdin(b []byte) os.Error {
 	return nil
 }
 
-// Read bytes from command stdout
-func (c *Command) ReadStdout() ([]byte, os.Error) {
-	b := make([]byte, 2048)
+// Read bytes from command stdout up to and including delim, restarting
+// the command if it fails to answer
+func (c *Command) ReadUntil(delim byte) ([]byte, os.Error) {
+	b, err := c.retry(func() ([][]byte, os.Error) {
+		return c.deadline(func(r *bufio.Reader) ([][]byte, os.Error) {
+			b, err := r.ReadBytes(delim)
+			if err != nil {
+				return nil, err
+			}
+
+			return [][]byte{b}, nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return b[0], nil
+}
+
+// Read a line from command stdout without its new line
+func (c *Command) ReadLine() ([]byte, os.Error) {
+	b, err := c.ReadLines(1)
+	if err != nil {
+		return nil, err
+	}
+
+	return b[0], nil
+}
+
+// Read n lines from command stdout without their new lines, restarting
+// the command if it fails to answer
+func (c *Command) ReadLines(n int) ([][]byte, os.Error) {
+	return c.retry(func() ([][]byte, os.Error) {
+		return c.readLines(n)
+	})
+}
+
+// Run read until it succeeds, restarting the command after each failure.
+// A response ends the interaction, so its writes are no longer pending
+// and its restarts no longer count against MaxRestarts.
+func (c *Command) retry(read func() ([][]byte, os.Error)) ([][]byte, os.Error) {
+	for {
+		b, err := read()
+		if err == nil {
+			c.pending = nil
+			c.attempts = 0
+			return b, nil
+		}
+
+		if err := c.restart(err); err != nil {
+			return nil, err
+		}
+	}
+
+	return nil, nil
+}
+
+// Read n lines from the running command stdout. The timeout covers all n
+// lines.
+func (c *Command) readLines(n int) ([][]byte, os.Error) {
+	return c.deadline(func(r *bufio.Reader) ([][]byte, os.Error) {
+		lines := make([][]byte, n)
+
+		for i := range lines {
+			b, err := r.ReadBytes(NEW_LINE)
+			if err != nil {
+				return nil, err
+			}
+
+			lines[i] = b[0 : len(b)-1]
+		}
+
+		return lines, nil
+	})
+}
+
+// Run read on the command stdout, giving up after Timeout. A read left
+// waiting on a hung command would steal the next response, so the
+// command must be run again before further reads.
+func (c *Command) deadline(read func(r *bufio.Reader) ([][]byte, os.Error)) ([][]byte, os.Error) {
+	if c.timedOut {
+		return nil, utils.NewError(fmt.Sprintf("command '%s' timed out and has not been run again", c.file))
+	}
+
+	// An abandoned read keeps the reader of the command it started on,
+	// never that of the command run in its place
+	r := c.reader
+
+	resCh := make(chan *response, 1)
+	go func() {
+		b, err := read(r)
+		resCh <- &response{b, err}
+	}()
+
+	// A nil channel is never ready, so no Timeout waits forever. The timer
+	// is stopped once the read returns so none are left running.
+	var timeoutCh <-chan int64
+	if c.Timeout > 0 {
+		timer := time.NewTimer(c.Timeout)
+		defer timer.Stop()
+		timeoutCh = timer.C
+	}
+
+	select {
+	case res := <-resCh:
+		if res.err != nil {
+			return nil, utils.Error("error reading command stdout", res.err)
+		}
+
+		return res.b, nil
+
+	case <-timeoutCh:
+		c.timedOut = true
+		return nil, utils.NewError(fmt.Sprintf("timed out after %.2fs reading command '%s' stdout", float64(c.Timeout)/1e9, c.file))
+	}
+
+	return nil, nil
+}
+
+// Run the command again after err, sending the warm up and the writes of
+// the interaction in progress
+func (c *Command) restart(err os.Error) os.Error {
+	for c.attempts < c.MaxRestarts {
+		c.attempts++
+		c.restarts++
+		c.stop()
+
+		if err = c.Run(); err != nil {
+			continue
+		}
+
+		if err = c.replay(); err == nil {
+			return nil
+		}
+	}
+
+	return utils.Error(fmt.Sprintf("command '%s' failed after %d restarts", c.file, c.attempts), err)
+}
+
+// Send the warm up then the pending writes to a freshly run command
+func (c *Command) replay() os.Error {
+	if c.warmUp != nil {
+		if err := c.write(c.warmUp); err != nil {
+			return err
+		}
+
+		if c.warmUpLines > 0 {
+			if _, err := c.readLines(c.warmUpLines); err != nil {
+				return utils.Error("failed to read warm up response", err)
+			}
+		}
+	}
+
+	for _, b := range c.pending {
+		if err := c.write(b); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
 
-	if _, err := c.cmd.Stdout.Read(b); err != nil {
-		return nil, utils.Error("error reading command stdout", err)
+// Kill a command which may have exited or hung, ignoring errors
+func (c *Command) stop() {
+	if c.cmd == nil {
+		return
 	}
 
-	return b, nil
+	syscall.Kill(c.cmd.Pid, syscall.SIGKILL)
+	c.cmd.Wait(0)
 }
 
 // Kill command
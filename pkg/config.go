@@ -0,0 +1,274 @@
+///////////////////////////////////////////////////////////
+//                                                       //
+//                 Joshua Van Leeuwen                    //
+//                                                       //
+//                University of Bristol                  //
+//                                                       //
+///////////////////////////////////////////////////////////
+
+package config
+
+import (
+	"big"
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"./file"
+	"./utils"
+)
+
+const (
+	HEX     = "hex"
+	DECIMAL = "decimal"
+
+	ENCODING = "encoding"
+)
+
+// Keyed attack config. Each line is a 'name = value' field, blank lines
+// and lines starting with # or ; are ignored. A file without fields is
+// read in the legacy layout of one value per line. Names given as name#
+// are indexed, also taking fields name2, name3 and on.
+type Config struct {
+	fileName string
+	fields   map[string]*field
+	legacy   bool
+}
+
+type field struct {
+	value string
+	line  int
+}
+
+// Initialise new Config from fileName. Legacy lines are named in order by
+// legacy, any field not in names is an error.
+func NewConfig(fileName string, names []string, legacy []string) (*Config, os.Error) {
+	fr, err := file.NewFileReader(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	lines, err := fr.ReadLines()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := fr.CloseFile(); err != nil {
+		return nil, err
+	}
+
+	c := &Config{
+		fileName: fileName,
+		fields:   make(map[string]*field),
+	}
+
+	if isLegacy(lines) {
+		return c, c.parseLegacy(lines, legacy)
+	}
+
+	return c, c.parse(lines, names)
+}
+
+// Read 'name = value' lines
+func (c *Config) parse(lines [][]byte, names []string) os.Error {
+	for i, line := range lines {
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+
+		k := bytes.IndexByte(line, '=')
+		if k < 0 {
+			return utils.NewError(fmt.Sprintf("conf '%s' line %d: expected 'name = value', got '%s'", c.fileName, i+1, line))
+		}
+
+		name := strings.ToLower(string(bytes.TrimSpace(line[0:k])))
+		if base, j := index(name); !contains(names, name) && (j < 2 || !contains(names, base+"#")) {
+			return utils.NewError(fmt.Sprintf("conf '%s' line %d: unknown field '%s'", c.fileName, i+1, name))
+		}
+
+		if f, ok := c.fields[name]; ok {
+			return utils.NewError(fmt.Sprintf("conf '%s' line %d: field '%s' already set on line %d", c.fileName, i+1, name, f.line))
+		}
+
+		c.fields[name] = &field{string(bytes.TrimSpace(line[k+1:])), i + 1}
+	}
+
+	return nil
+}
+
+// Read one value per line, named in order
+func (c *Config) parseLegacy(lines [][]byte, legacy []string) os.Error {
+	c.legacy = true
+
+	for i, line := range lines {
+		if i >= len(legacy) {
+			if len(bytes.TrimSpace(line)) == 0 {
+				continue
+			}
+			return utils.NewError(fmt.Sprintf("conf '%s' line %d: expected %d lines", c.fileName, i+1, len(legacy)))
+		}
+
+		c.fields[legacy[i]] = &field{string(bytes.TrimSpace(line)), i + 1}
+	}
+
+	return nil
+}
+
+// Whether the file was in the legacy layout
+func (c *Config) Legacy() bool { return c.legacy }
+
+// Whether field name is set
+func (c *Config) Has(name string) bool {
+	_, ok := c.fields[name]
+	return ok
+}
+
+// Number of fields name, name2 and on of an indexed name#, each of which
+// must be set up to the last
+func (c *Config) Count(name string) (int, os.Error) {
+	n := 1
+	for c.Has(fmt.Sprintf("%s%d", name, n+1)) {
+		n++
+	}
+
+	for f := range c.fields {
+		if base, j := index(f); base == name && j > n {
+			return 0, c.Error(f, fmt.Sprintf("missing field '%s%d'", name, n+1))
+		}
+	}
+
+	return n, nil
+}
+
+// Return the value of field name, which must be set
+func (c *Config) String(name string) (string, os.Error) {
+	f, ok := c.fields[name]
+	if !ok {
+		return "", utils.NewError(fmt.Sprintf("conf '%s': missing field '%s'", c.fileName, name))
+	}
+
+	return f.value, nil
+}
+
+// Return the value of field name, or def if not set
+func (c *Config) StringDefault(name, def string) string {
+	if f, ok := c.fields[name]; ok {
+		return f.value
+	}
+
+	return def
+}
+
+// Return the value of field name, which must be one of values
+func (c *Config) Choice(name, def string, values []string) (string, os.Error) {
+	v := strings.ToLower(c.StringDefault(name, def))
+	if !contains(values, v) {
+		return "", c.Error(name, fmt.Sprintf("expected one of %s, got '%s'", strings.Join(values, ", "), v))
+	}
+
+	return v, nil
+}
+
+// Return field name as an integer in the file encoding, along with the
+// number of digits
+func (c *Config) Int(name string) (*big.Int, int, os.Error) {
+	v, err := c.String(name)
+	if err != nil {
+		return nil, -1, err
+	}
+
+	enc, err := c.Choice(ENCODING, HEX, []string{HEX, DECIMAL})
+	if err != nil {
+		return nil, -1, err
+	}
+
+	base := 16
+	if enc == DECIMAL {
+		base = 10
+	}
+
+	// Fields are never negative, so no sign is taken
+	z, ok := new(big.Int).SetString(v, base)
+	if !ok || len(v) == 0 || v[0] == '-' || v[0] == '+' {
+		return nil, -1, c.Error(name, fmt.Sprintf("expected an unsigned %s integer, got '%s'", enc, v))
+	}
+
+	return z, len(v), nil
+}
+
+// Return field name as hex encoded octets
+func (c *Config) Octets(name string) ([]byte, os.Error) {
+	v, err := c.String(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(v)%2 != 0 {
+		return nil, c.Error(name, fmt.Sprintf("expected hex octets, got odd length %d", len(v)))
+	}
+
+	b := make([]byte, len(v)/2)
+	if _, err := hex.Decode(b, []byte(v)); err != nil {
+		return nil, c.Error(name, fmt.Sprintf("expected hex octets, got '%s'", v))
+	}
+
+	return b, nil
+}
+
+// Error naming field name and its line
+func (c *Config) Error(name, msg string) os.Error {
+	if f, ok := c.fields[name]; ok {
+		return utils.NewError(fmt.Sprintf("conf '%s' line %d: field '%s': %s", c.fileName, f.line, name, msg))
+	}
+
+	return utils.NewError(fmt.Sprintf("conf '%s': field '%s': %s", c.fileName, name, msg))
+}
+
+// A file is legacy if its first value line is not a field
+func isLegacy(lines [][]byte) bool {
+	for _, line := range lines {
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+
+		return bytes.IndexByte(line, '=') < 0
+	}
+
+	return false
+}
+
+// Split name into its base and index, 0 if it has none. Indices have no
+// leading zeros.
+func index(name string) (string, int) {
+	k := len(name)
+	for k > 0 && name[k-1] >= '0' && name[k-1] <= '9' {
+		k--
+	}
+
+	if k == len(name) || name[k] == '0' {
+		return name, 0
+	}
+
+	j, err := strconv.Atoi(name[k:])
+	if err != nil {
+		return name, 0
+	}
+
+	return name[0:k], j
+}
+
+// Whether x is in xs
+func contains(xs []string, x string) bool {
+	for _, y := range xs {
+		if x == y {
+			return true
+		}
+	}
+
+	return false
+}
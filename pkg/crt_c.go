@@ -0,0 +1,136 @@
+///////////////////////////////////////////////////////////
+//                                                       //
+//                 Joshua Van Leeuwen                    //
+//                                                       //
+//                University of Bristol                  //
+//                                                       //
+///////////////////////////////////////////////////////////
+
+package crt_c
+
+import (
+	"big"
+	"os"
+
+	"./config"
+	"./utils"
+)
+
+var (
+	// Fields of a keyed conf, and the order of a legacy one. A faulty
+	// signature given with its message or correct signature is attacked
+	// without the target.
+	FIELDS = []string{"n", "e", "message", "signature", "faulty", "encoding"}
+	LEGACY = []string{"n", "e"}
+)
+
+type Conf struct {
+	N *big.Int
+	E *big.Int
+
+	// Message, correct and faulty signature, nil unless given
+	M *big.Int
+	S *big.Int
+	F *big.Int
+}
+
+// Initialise new RSA-CRT Conf struct
+func NewConf(fileName string) (*Conf, os.Error) {
+	cfg, err := config.NewConfig(fileName, FIELDS, LEGACY)
+	if err != nil {
+		return nil, err
+	}
+
+	conf := new(Conf)
+
+	if conf.N, _, err = cfg.Int("n"); err != nil {
+		return nil, err
+	}
+
+	if conf.E, _, err = cfg.Int("e"); err != nil {
+		return nil, err
+	}
+
+	if conf.E.Cmp(big.NewInt(1)) <= 0 || conf.E.Cmp(conf.N) >= 0 {
+		return nil, cfg.Error("e", "expected 1 < e < N")
+	}
+
+	if conf.M, err = optional(cfg, "message", conf.N); err != nil {
+		return nil, err
+	}
+
+	if conf.S, err = optional(cfg, "signature", conf.N); err != nil {
+		return nil, err
+	}
+
+	if conf.F, err = optional(cfg, "faulty", conf.N); err != nil {
+		return nil, err
+	}
+
+	if conf.F != nil && conf.M == nil && conf.S == nil {
+		return nil, cfg.Error("faulty", "expected a message or correct signature with the faulty signature")
+	}
+
+	return conf, nil
+}
+
+// Read field name under N if set, else nil
+func optional(cfg *config.Config, name string, N *big.Int) (*big.Int, os.Error) {
+	if !cfg.Has(name) {
+		return nil, nil
+	}
+
+	z, _, err := cfg.Int(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if z.Cmp(N) >= 0 {
+		return nil, cfg.Error(name, "expected a value < N")
+	}
+
+	return z, nil
+}
+
+// Whether the conf holds signatures to attack without the target
+func (c *Conf) Offline() bool { return c.F != nil }
+
+// Factor N from a correct signature s and a faulty one f of the same
+// message. s - f is 0 mod the prime whose half was not faulted.
+func (c *Conf) FactorPair(s, f *big.Int) (p, q *big.Int, err os.Error) {
+	return c.factor(new(big.Int).Sub(s, f))
+}
+
+// Factor N from a faulty signature f of message m, as f^e - m is 0 mod
+// the prime whose half was not faulted
+func (c *Conf) FactorMessage(m, f *big.Int) (p, q *big.Int, err os.Error) {
+	x := new(big.Int).Exp(f, c.E, c.N)
+	return c.factor(x.Sub(x, m))
+}
+
+// Split N by gcd(x, N)
+func (c *Conf) factor(x *big.Int) (p, q *big.Int, err os.Error) {
+	x.Mod(x, c.N)
+	if x.Sign() == 0 {
+		return nil, nil, utils.NewError("signature not faulted")
+	}
+
+	p = new(big.Int)
+	big.GcdInt(p, new(big.Int), new(big.Int), x, c.N)
+
+	if p.Cmp(big.NewInt(1)) == 0 {
+		return nil, nil, utils.NewError("both CRT halves faulted, gcd is 1")
+	}
+
+	q, r := new(big.Int).Div(c.N, p)
+	if r.Sign() != 0 {
+		return nil, nil, utils.NewError("gcd does not divide N")
+	}
+
+	// p < q
+	if p.Cmp(q) > 0 {
+		p, q = q, p
+	}
+
+	return p, q, nil
+}
@@ -0,0 +1,238 @@
+///////////////////////////////////////////////////////////
+//                                                       //
+//                 Joshua Van Leeuwen                    //
+//                                                       //
+//                University of Bristol                  //
+//                                                       //
+///////////////////////////////////////////////////////////
+
+package crt_s
+
+import (
+	"big"
+	"bufio"
+	"bytes"
+	crand "crypto/rand"
+	"crypto/rsa"
+	"fmt"
+	"io"
+	"os"
+	"rand"
+	"time"
+
+	"./file"
+	"./utils"
+)
+
+const (
+	// Fault lines naming the CRT half to fault
+	FAULT_P = "p"
+	FAULT_Q = "q"
+)
+
+// Simulator of an RSA-CRT signing target. Signs each message as an
+// integer with s = m^d mod N, computed mod p and q and recombined, adding
+// a random error to the half the fault line names.
+type Simulator struct {
+	N *big.Int
+	E *big.Int
+
+	d *big.Int
+	p *big.Int
+	q *big.Int
+
+	// d mod p-1, d mod q-1 and q^-1 mod p
+	dp   *big.Int
+	dq   *big.Int
+	qInv *big.Int
+
+	rnd *rand.Rand
+}
+
+// Initialise new Simulator with a freshly generated key of bits length
+func NewSimulator(bits int) (*Simulator, os.Error) {
+	priv, err := rsa.GenerateKey(crand.Reader, bits)
+	if err != nil {
+		return nil, utils.Error("failed to generate RSA key", err)
+	}
+
+	return NewSimulatorKey(priv.N, big.NewInt(int64(priv.E)), priv.D, priv.P, priv.Q), nil
+}
+
+// Initialise new Simulator from a key file of hex lines N, e, d, p and q
+func NewSimulatorFile(fileName string) (*Simulator, os.Error) {
+	fr, err := file.NewFileReader(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	key := make([]*big.Int, 5)
+	for i, name := range []string{"N", "e", "d", "p", "q"} {
+		if key[i], err = fr.ReadInt(); err != nil {
+			return nil, utils.Error(fmt.Sprintf("failed to get %s", name), err)
+		}
+	}
+
+	if err := fr.CloseFile(); err != nil {
+		return nil, err
+	}
+
+	return NewSimulatorKey(key[0], key[1], key[2], key[3], key[4]), nil
+}
+
+// Initialise new Simulator from an RSA key and its primes
+func NewSimulatorKey(N, e, d, p, q *big.Int) *Simulator {
+	one := big.NewInt(1)
+
+	return &Simulator{
+		N:    N,
+		E:    e,
+		d:    d,
+		p:    p,
+		q:    q,
+		dp:   new(big.Int).Mod(d, new(big.Int).Sub(p, one)),
+		dq:   new(big.Int).Mod(d, new(big.Int).Sub(q, one)),
+		qInv: utils.ModInverse(q, p),
+		rnd:  rand.New(rand.NewSource(time.Nanoseconds())),
+	}
+}
+
+// Seed the fault source so runs can be reproduced
+func (s *Simulator) Seed(seed int64) { s.rnd = rand.New(rand.NewSource(seed)) }
+
+// Smaller prime of N
+func (s *Simulator) P() *big.Int {
+	if s.p.Cmp(s.q) < 0 {
+		return s.p
+	}
+	return s.q
+}
+
+// Simulator is in process so there is nothing to start, stop or restart
+func (s *Simulator) Run() os.Error  { return nil }
+func (s *Simulator) Kill() os.Error { return nil }
+func (s *Simulator) Restarts() int  { return 0 }
+
+// Sign m with the fault line given, as the target answers a FaultOracle
+func (s *Simulator) Encrypt(fault []byte, m []byte) ([]byte, os.Error) {
+	sig, err := s.Sign(fault, new(big.Int).SetBytes(m))
+	if err != nil {
+		return nil, err
+	}
+
+	return sig.Bytes(), nil
+}
+
+// Sign m by the CRT, faulting the half named by the fault line. An empty
+// line injects no fault.
+func (s *Simulator) Sign(fault []byte, m *big.Int) (*big.Int, os.Error) {
+	if m.Cmp(s.N) >= 0 {
+		return nil, utils.NewError("message out of range")
+	}
+
+	f := string(bytes.TrimSpace(fault))
+	if f != "" && f != FAULT_P && f != FAULT_Q {
+		return nil, utils.NewError(fmt.Sprintf("unknown fault '%s', expected %s or %s", f, FAULT_P, FAULT_Q))
+	}
+
+	sp := new(big.Int).Exp(m, s.dp, s.p)
+	sq := new(big.Int).Exp(m, s.dq, s.q)
+
+	switch f {
+	case FAULT_P:
+		s.inject(sp, s.p)
+	case FAULT_Q:
+		s.inject(sq, s.q)
+	}
+
+	// s = sq + q ((sp - sq) qInv mod p)
+	h := new(big.Int).Sub(sp, sq)
+	h.Mul(h, s.qInv)
+	h.Mod(h, s.p)
+
+	return h.Mul(h, s.q).Add(h, sq), nil
+}
+
+// Add a random non zero error to x mod prime
+func (s *Simulator) inject(x, prime *big.Int) {
+	x.Add(x, big.NewInt(s.rnd.Int63()|1))
+	x.Mod(x, prime)
+}
+
+// Write the key as hex lines N, e, d, p and q, readable by the owner
+// only
+func (s *Simulator) WriteKey(fileName string) os.Error {
+	fw, err := file.NewFileWriterMode(fileName, 0600)
+	if err != nil {
+		return err
+	}
+
+	for _, z := range []*big.Int{s.N, s.E, s.d, s.p, s.q} {
+		if err := fw.WriteInt(z); err != nil {
+			return err
+		}
+	}
+
+	return fw.CloseFile()
+}
+
+// Write a keyed attack conf of hex fields N and e
+func (s *Simulator) WriteConf(fileName string) os.Error {
+	fw, err := file.NewFileWriter(fileName)
+	if err != nil {
+		return err
+	}
+
+	lines := []string{
+		"# crt attack conf",
+		fmt.Sprintf("N = %X", s.N.Bytes()),
+		fmt.Sprintf("e = %X", s.E.Bytes()),
+	}
+
+	for _, line := range lines {
+		if err := fw.WriteLine([]byte(line)); err != nil {
+			return err
+		}
+	}
+
+	return fw.CloseFile()
+}
+
+// Answer fault and message line pairs from r with a signature line on w
+// until r is closed
+func (s *Simulator) Serve(r io.Reader, w io.Writer) os.Error {
+	reader := bufio.NewReader(r)
+
+	for {
+		fault, err := reader.ReadBytes('\n')
+		if err == os.EOF {
+			return nil
+		}
+		if err != nil {
+			return utils.Error("failed to read fault", err)
+		}
+
+		mb, err := reader.ReadBytes('\n')
+		if err != nil {
+			return utils.Error("failed to read message", err)
+		}
+
+		m := new(big.Int)
+		if mb = bytes.TrimSpace(mb); len(mb) > 0 {
+			if _, ok := m.SetString(string(mb), 16); !ok {
+				return utils.NewError(fmt.Sprintf("failed to decode message '%s'", mb))
+			}
+		}
+
+		sig, err := s.Sign(fault, m)
+		if err != nil {
+			return err
+		}
+
+		if _, err := fmt.Fprintf(w, "%X\n", sig.Bytes()); err != nil {
+			return utils.Error("failed to write signature", err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,228 @@
+///////////////////////////////////////////////////////////
+//                                                       //
+//                 Joshua Van Leeuwen                    //
+//                                                       //
+//                University of Bristol                  //
+//                                                       //
+///////////////////////////////////////////////////////////
+
+package fault_s
+
+import (
+	"bufio"
+	"bytes"
+	crand "crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"rand"
+	"strconv"
+	"time"
+
+	"./rijndael"
+	"./utils"
+)
+
+const (
+	BLOCK_SIZE = rijndael.BLOCK_SIZE
+	ROUNDS     = rijndael.ROUNDS
+
+	ADD_ROUND_KEY = 0
+	SUB_BYTES     = 1
+	SHIFT_ROWS    = 2
+	MIX_COLUMNS   = 3
+
+	BEFORE = 0
+	AFTER  = 1
+)
+
+// Fault specification, a random byte fault is injected into row i column
+// j of the state either before or after function f of round r
+type Fault struct {
+	R, F, P, I, J int
+}
+
+// Simulator of the fault target D. Encrypts each message with AES-128,
+// injecting the requested fault.
+type Simulator struct {
+	key []byte
+	rk  [][]byte
+
+	rnd *rand.Rand
+}
+
+// Initialise new Simulator with a random key
+func NewSimulator() (*Simulator, os.Error) {
+	key := make([]byte, BLOCK_SIZE)
+	if _, err := io.ReadFull(crand.Reader, key); err != nil {
+		return nil, utils.Error("failed to generate key", err)
+	}
+
+	return NewSimulatorKey(key)
+}
+
+// Initialise new Simulator with a 16 byte key
+func NewSimulatorKey(key []byte) (*Simulator, os.Error) {
+	if len(key) != BLOCK_SIZE {
+		return nil, utils.NewError(fmt.Sprintf("expected key of %d bytes, got=%d", BLOCK_SIZE, len(key)))
+	}
+
+	s := &Simulator{
+		key: key,
+		rk:  rijndael.ExpandKey(key),
+		rnd: rand.New(rand.NewSource(time.Nanoseconds())),
+	}
+
+	return s, nil
+}
+
+// Return the cipher key
+func (s *Simulator) Key() []byte { return s.key }
+
+// Seed the fault source so runs can be reproduced
+func (s *Simulator) Seed(seed int64) { s.rnd = rand.New(rand.NewSource(seed)) }
+
+// Simulator is in process so there is nothing to start, stop or restart
+func (s *Simulator) Run() os.Error  { return nil }
+func (s *Simulator) Kill() os.Error { return nil }
+func (s *Simulator) Restarts() int  { return 0 }
+
+// Encrypt m with the fault line given, an empty line injects no fault
+func (s *Simulator) Encrypt(fault []byte, m []byte) ([]byte, os.Error) {
+	f, err := ParseFault(fault)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(m) > BLOCK_SIZE {
+		return nil, utils.NewError(fmt.Sprintf("message longer than %d bytes", BLOCK_SIZE))
+	}
+
+	state := make([]byte, BLOCK_SIZE)
+	copy(state[BLOCK_SIZE-len(m):], m)
+
+	s.encrypt(state, f)
+
+	return state, nil
+}
+
+// Encrypt state in place, injecting f if not nil
+func (s *Simulator) encrypt(state []byte, f *Fault) {
+	s.round(state, 0, ADD_ROUND_KEY, f)
+
+	for r := 1; r <= ROUNDS; r++ {
+		s.round(state, r, SUB_BYTES, f)
+		s.round(state, r, SHIFT_ROWS, f)
+		if r != ROUNDS {
+			s.round(state, r, MIX_COLUMNS, f)
+		}
+		s.round(state, r, ADD_ROUND_KEY, f)
+	}
+}
+
+// Apply round function fn of round r to state with the fault around it
+func (s *Simulator) round(state []byte, r, fn int, f *Fault) {
+	if f != nil && f.R == r && f.F == fn && f.P == BEFORE {
+		s.inject(state, f)
+	}
+
+	switch fn {
+	case ADD_ROUND_KEY:
+		rijndael.AddRoundKey(state, s.rk[r])
+	case SUB_BYTES:
+		rijndael.SubBytes(state)
+	case SHIFT_ROWS:
+		rijndael.ShiftRows(state)
+	case MIX_COLUMNS:
+		rijndael.MixColumns(state)
+	}
+
+	if f != nil && f.R == r && f.F == fn && f.P == AFTER {
+		s.inject(state, f)
+	}
+}
+
+// XOR a random non zero byte into row i column j of the state
+func (s *Simulator) inject(state []byte, f *Fault) {
+	state[f.I+4*f.J] ^= byte(s.rnd.Intn(255) + 1)
+}
+
+// Answer fault and message line pairs from r with a ciphertext line on w
+// until r is closed
+func (s *Simulator) Serve(r io.Reader, w io.Writer) os.Error {
+	reader := bufio.NewReader(r)
+
+	for {
+		fault, err := reader.ReadBytes('\n')
+		if err == os.EOF {
+			return nil
+		}
+		if err != nil {
+			return utils.Error("failed to read fault", err)
+		}
+
+		mb, err := reader.ReadBytes('\n')
+		if err != nil {
+			return utils.Error("failed to read message", err)
+		}
+
+		mb = bytes.TrimSpace(mb)
+		if len(mb)%2 != 0 {
+			mb = utils.AppendByteSlice([]byte{'0'}, mb)
+		}
+
+		m := make([]byte, len(mb)/2)
+		if _, err := hex.Decode(m, mb); err != nil {
+			return utils.Error("failed to decode message", err)
+		}
+
+		c, err := s.Encrypt(fault, m)
+		if err != nil {
+			return err
+		}
+
+		if _, err := fmt.Fprintf(w, "%X\n", c); err != nil {
+			return utils.Error("failed to write cipher text", err)
+		}
+	}
+
+	return nil
+}
+
+// Parse a fault line r,f,p,i,j. An empty line is no fault.
+func ParseFault(b []byte) (*Fault, os.Error) {
+	b = bytes.TrimSpace(b)
+	if len(b) == 0 {
+		return nil, nil
+	}
+
+	split := bytes.Split(b, []byte{','}, 0)
+	if len(split) != 5 {
+		return nil, utils.NewError(fmt.Sprintf("expected 5 fault parameters, got=%d", len(split)))
+	}
+
+	v := make([]int, 5)
+	for k := range split {
+		n, err := strconv.Atoi(string(split[k]))
+		if err != nil {
+			return nil, utils.Error("failed to parse fault parameter", err)
+		}
+		v[k] = n
+	}
+
+	f := &Fault{R: v[0], F: v[1], P: v[2], I: v[3], J: v[4]}
+
+	switch {
+	case f.R < 0 || f.R > ROUNDS:
+		return nil, utils.NewError(fmt.Sprintf("fault round out of range, r=%d", f.R))
+	case f.F < ADD_ROUND_KEY || f.F > MIX_COLUMNS:
+		return nil, utils.NewError(fmt.Sprintf("fault function out of range, f=%d", f.F))
+	case f.P != BEFORE && f.P != AFTER:
+		return nil, utils.NewError(fmt.Sprintf("fault position out of range, p=%d", f.P))
+	case f.I < 0 || f.I > 3 || f.J < 0 || f.J > 3:
+		return nil, utils.NewError(fmt.Sprintf("fault row or column out of range, i=%d j=%d", f.I, f.J))
+	}
+
+	return f, nil
+}
@@ -11,6 +11,7 @@ package file
 import (
 	"big"
 	"bufio"
+	"bytes"
 	"fmt"
 	"os"
 	"syscall"
@@ -29,6 +30,11 @@ type FileReader struct {
 	reader   *bufio.Reader
 }
 
+type FileWriter struct {
+	file     *os.File
+	filename string
+}
+
 // Initialise new FileReader struct
 func NewFileReader(filename string) (*FileReader, os.Error) {
 	f, err := os.Open(filename, syscall.O_RDONLY, 666)
@@ -91,6 +97,31 @@ func (f *FileReader) ReadLine() ([]byte, os.Error) {
 	return b, nil
 }
 
+// Read all remaining lines from file, without their new lines. The last
+// line need not end in a new line.
+func (f *FileReader) ReadLines() ([][]byte, os.Error) {
+	var lines [][]byte
+
+	for {
+		b, err := f.reader.ReadBytes(NewLine)
+		if len(b) > 0 {
+			if b[len(b)-1] == NewLine {
+				b = b[0 : len(b)-1]
+			}
+			lines = utils.AppendByte2(lines, b)
+		}
+
+		if err == os.EOF {
+			return lines, nil
+		}
+		if err != nil {
+			return nil, utils.Error("failed to read bytes from file", err)
+		}
+	}
+
+	return lines, nil
+}
+
 // Close file
 func (f *FileReader) CloseFile() os.Error {
 	if err := f.file.Close(); err != nil {
@@ -99,3 +130,62 @@ func (f *FileReader) CloseFile() os.Error {
 
 	return nil
 }
+
+// Initialise new FileWriter struct, truncating any existing file
+func NewFileWriter(filename string) (*FileWriter, os.Error) {
+	return NewFileWriterMode(filename, 0644)
+}
+
+// Initialise new FileWriter struct, truncating any existing file and
+// setting its permissions to mode
+func NewFileWriterMode(filename string, mode uint32) (*FileWriter, os.Error) {
+	f, err := os.Open(filename, syscall.O_WRONLY|syscall.O_CREAT|syscall.O_TRUNC, mode)
+	if err != nil {
+		return nil, utils.Error(fmt.Sprintf("failed to create file '%s'", filename), err)
+	}
+
+	// An existing file keeps its permissions when opened
+	if err := f.Chmod(mode); err != nil {
+		f.Close()
+		return nil, utils.Error(fmt.Sprintf("failed to set permissions of file '%s'", filename), err)
+	}
+
+	return &FileWriter{
+			file:     f,
+			filename: filename,
+		},
+		nil
+}
+
+// Write *big.Int to file as a hex line
+func (f *FileWriter) WriteInt(z *big.Int) os.Error {
+	return f.WriteLine([]byte(fmt.Sprintf("%X", z.Bytes())))
+}
+
+// Write bytes to file followed by a new line
+func (f *FileWriter) WriteLine(b []byte) os.Error {
+	if _, err := f.file.Write(bytes.AddByte(b, NewLine)); err != nil {
+		return utils.Error(fmt.Sprintf("failed to write to file '%s'", f.filename), err)
+	}
+
+	return nil
+}
+
+// Write bytes to file as they are, so a FileWriter is an io.Writer
+func (f *FileWriter) Write(b []byte) (int, os.Error) {
+	n, err := f.file.Write(b)
+	if err != nil {
+		return n, utils.Error(fmt.Sprintf("failed to write to file '%s'", f.filename), err)
+	}
+
+	return n, nil
+}
+
+// Close file
+func (f *FileWriter) CloseFile() os.Error {
+	if err := f.file.Close(); err != nil {
+		return utils.Error(fmt.Sprintf("failed to close file '%s'", f.filename), err)
+	}
+
+	return nil
+}
@@ -109,23 +109,41 @@ func (m *Montgomery) Mul(x, y *big.Int) (r *big.Int, red bool) {
 
 // Calculate Montgomery exponentiation
 func (m *Montgomery) Exp(x, y *big.Int) *big.Int {
-	t_hat, _ := m.Mul(big.NewInt(1), m.Ro2)
-	x_hat, _ := m.Mul(x, m.Ro2)
+	t_hat, _ := m.ExpRed(x, y, WINDOW_SIZE)
+	return t_hat
+}
+
+// Calculate Montgomery exponentiation with a sliding window of size w,
+// also returning the number of extra reductions performed. A window of 1
+// is left to right square and multiply.
+func (m *Montgomery) ExpRed(x, y *big.Int, w int) (*big.Int, int) {
+	var reds int
+
+	mul := func(a, b *big.Int) *big.Int {
+		r, red := m.Mul(a, b)
+		if red {
+			reds++
+		}
+		return r
+	}
 
-	x0, _ := m.Mul(x_hat, x_hat)
+	t_hat := mul(big.NewInt(1), m.Ro2)
+	x_hat := mul(x, m.Ro2)
 
-	T := make([]*big.Int, 32)
+	T := make([]*big.Int, 1<<uint(w-1))
 	T[0] = new(big.Int)
 	T[0].Set(x_hat)
-	for i := 1; i < 32; i++ {
-		T[i] = new(big.Int)
-		T[i], _ = m.Mul(T[i-1], x0)
+	if len(T) > 1 {
+		x0 := mul(x_hat, x_hat)
+		for i := 1; i < len(T); i++ {
+			T[i] = new(big.Int)
+			T[i] = mul(T[i-1], x0)
+		}
 	}
 
 	i := (len(y.Bytes()) * BYTES_PER_LIMB) - 1
 	var u int
 	var l int
-	WINDOW_SIZE := 6
 
 	for i >= 0 {
 		if bit(y, i) == 0 {
@@ -134,7 +152,7 @@ func (m *Montgomery) Exp(x, y *big.Int) *big.Int {
 
 		} else {
 
-			l = max(i-WINDOW_SIZE+1, 0)
+			l = max(i-w+1, 0)
 
 			for bit(y, l) == 0 {
 				l++
@@ -156,17 +174,17 @@ func (m *Montgomery) Exp(x, y *big.Int) *big.Int {
 		}
 
 		for j := 0; j < i-l+1; j++ {
-			t_hat, _ = m.Mul(t_hat, t_hat)
+			t_hat = mul(t_hat, t_hat)
 		}
 		if u != 0 {
-			t_hat, _ = m.Mul(t_hat, T[(u-1)/2])
+			t_hat = mul(t_hat, T[(u-1)/2])
 		}
 
 		i = l - 1
 
 	}
 
-	return t_hat
+	return t_hat, reds
 }
 
 // Calculate Montgomery reduction
@@ -229,6 +247,11 @@ func getLimb(z *big.Int, s int) *big.Int {
 	e := len(b) - (s * BYTES_PER_LIMB)
 	s = len(b) - BYTES_PER_LIMB - (s * BYTES_PER_LIMB)
 
+	// Limbs past the top of z are zero
+	if e <= 0 {
+		return new(big.Int)
+	}
+
 	if s < 0 {
 		s = 0
 	}
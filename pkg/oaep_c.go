@@ -10,17 +10,42 @@ package oaep_c
 
 import (
 	"big"
-	"bytes"
 	"crypto/sha1"
-	"math"
+	"crypto/sha256"
+	"crypto/sha512"
+	"fmt"
+	"hash"
 	"os"
 
-	"./file"
+	"./config"
+	"./pkcs1"
 	"./utils"
 )
 
 const (
 	MAX_LENGTH = 2 << 31
+
+	SHA1   = "sha1"
+	SHA224 = "sha224"
+	SHA256 = "sha256"
+	SHA384 = "sha384"
+	SHA512 = "sha512"
+)
+
+var (
+	// Fields of a keyed conf, and the order of a legacy one. Further
+	// ciphertexts are given as label2 and ciphertext2 on.
+	FIELDS = []string{"n", "e", "label", "ciphertext", "label#", "ciphertext#", "hash", "mgf", "encoding"}
+	LEGACY = []string{"n", "e", "label", "ciphertext"}
+
+	HASHES = []string{SHA1, SHA224, SHA256, SHA384, SHA512}
+	hashes = map[string]func() hash.Hash{
+		SHA1:   sha1.New,
+		SHA224: sha256.New224,
+		SHA256: sha256.New,
+		SHA384: sha512.New384,
+		SHA512: sha512.New,
+	}
 )
 
 type Conf struct {
@@ -29,114 +54,222 @@ type Conf struct {
 	L []byte
 	C *big.Int
 
+	// Label octets, L is the hex line sent to the target
+	Label []byte
+
 	K *big.Int
 	B *big.Int
+
+	// Hash of the label and seed, and hash of MGF1
+	Hash string
+	MGF  string
+
+	// Every ciphertext of the conf, the first being C
+	Ciphertexts []*Ciphertext
+}
+
+// A ciphertext and its label
+type Ciphertext struct {
+	L     []byte
+	Label []byte
+	C     *big.Int
 }
 
 // Initialise new OEAP Conf struct
 func NewConf(fileName string) (*Conf, os.Error) {
-	fr, err := file.NewFileReader(fileName)
+	cfg, err := config.NewConfig(fileName, FIELDS, LEGACY)
 	if err != nil {
 		return nil, err
-
 	}
 
 	conf := new(Conf)
-	var k int
 
-	if conf.N, k, err = fr.ReadIntLen(); err != nil {
-		return nil, utils.Error("failed to get N", err)
+	if conf.N, _, err = cfg.Int("n"); err != nil {
+		return nil, err
 	}
 
-	if conf.E, err = fr.ReadInt(); err != nil {
-		return nil, utils.Error("failed to get e", err)
+	if conf.E, _, err = cfg.Int("e"); err != nil {
+		return nil, err
 	}
 
-	if conf.L, err = fr.ReadLine(); err != nil {
-		return nil, utils.Error("failed to get l", err)
+	n, err := cfg.Count("ciphertext")
+	if err != nil {
+		return nil, err
+	}
+
+	labels, err := cfg.Count("label")
+	if err != nil {
+		return nil, err
+	}
+
+	if labels > n {
+		n = labels
+	}
+
+	conf.Ciphertexts = make([]*Ciphertext, n)
+	for i := range conf.Ciphertexts {
+		if conf.Ciphertexts[i], err = ciphertext(cfg, i); err != nil {
+			return nil, err
+		}
 	}
 
-	if conf.C, err = fr.ReadInt(); err != nil {
-		return nil, utils.Error("failed to get c", err)
+	conf.L = conf.Ciphertexts[0].L
+	conf.Label = conf.Ciphertexts[0].Label
+	conf.C = conf.Ciphertexts[0].C
+
+	if conf.Hash, err = cfg.Choice("hash", SHA1, HASHES); err != nil {
+		return nil, err
 	}
 
-	if err := fr.CloseFile(); err != nil {
+	if conf.MGF, err = cfg.Choice("mgf", conf.Hash, HASHES); err != nil {
 		return nil, err
 	}
 
-	conf.K = big.NewInt(int64(k / 2))
+	if conf.E.Cmp(big.NewInt(1)) <= 0 || conf.E.Cmp(conf.N) >= 0 {
+		return nil, cfg.Error("e", "expected 1 < e < N")
+	}
+
+	for i, ct := range conf.Ciphertexts {
+		if ct.C.Cmp(conf.N) >= 0 {
+			return nil, cfg.Error(indexed("ciphertext", i), "expected ciphertext < N")
+		}
+	}
+
+	if conf.K, conf.B, err = Bounds(conf.N); err != nil {
+		return nil, cfg.Error("n", err.String())
+	}
 
-	conf.B = new(big.Int).Sub(conf.K, big.NewInt(1))
-	conf.B.Mul(conf.B, big.NewInt(8))
-	conf.B.Exp(big.NewInt(2), conf.B, nil)
+	if err := conf.SetHash("", ""); err != nil {
+		return nil, cfg.Error("hash", err.String())
+	}
 
 	return conf, nil
 }
 
-// Calculate RSA encryption on f using pk
-func (c *Conf) RSAf(f *big.Int) *big.Int {
-	z := new(big.Int).Exp(f, c.E, c.N)
+// Length K of N in octets and B = 2^(8(K-1)), taken from the bits of N
+// whatever its encoding. Fails unless 2B < N, else f m in [B, 2B) could
+// wrap N and the responses would not bound m. K and B are still given.
+func Bounds(N *big.Int) (K, B *big.Int, err os.Error) {
+	if N.Sign() <= 0 {
+		return nil, nil, utils.NewError("expected N > 0")
+	}
 
-	z.Mul(z, c.C)
-	z = z.Mod(z, c.N)
+	k := (N.Len() + 7) / 8
 
-	return z
+	K = big.NewInt(int64(k))
+	B = new(big.Int).Lsh(big.NewInt(1), uint(8*(k-1)))
+
+	if new(big.Int).Lsh(B, 1).Cmp(N) >= 0 {
+		err = utils.NewError(fmt.Sprintf("expected 2B < N for B = 2^%d, got N of %d bits with leading octet %02X", 8*(k-1), N.Len(), N.Bytes()[0]))
+	}
+
+	return K, B, err
 }
 
-// MGF1 function
-func (c *Conf) MGF1(Z []byte, l int64) (mask []byte, err os.Error) {
-	if l > MAX_LENGTH {
-		return nil, utils.NewError("mask too long")
+// Read ciphertext i and its label from cfg
+func ciphertext(cfg *config.Config, i int) (*Ciphertext, os.Error) {
+	ct := new(Ciphertext)
+	var err os.Error
+
+	if ct.Label, err = cfg.Octets(indexed("label", i)); err != nil {
+		return nil, err
 	}
 
-	hash := sha1.New()
-	hLen := int64(hash.Size())
-	var T []byte
+	label, _ := cfg.String(indexed("label", i))
+	ct.L = []byte(label + "\n")
 
-	v := float64(l) / float64(hLen)
-	v = math.Ceil(v)
+	if ct.C, _, err = cfg.Int(indexed("ciphertext", i)); err != nil {
+		return nil, err
+	}
 
-	for i := int64(0); i < int64(v); i++ {
-		hash = sha1.New()
-		C, err := c.I2OSP(i, 4)
-		if err != nil {
-			return nil, err
-		}
+	return ct, nil
+}
 
-		z := utils.AppendByteSlice(Z, C)
-		_, err = hash.Write(z)
-		if err != nil {
-			return nil, err
-		}
+// Field name of ciphertext i, numbered from 2 after the first
+func indexed(name string, i int) string {
+	if i == 0 {
+		return name
+	}
 
-		h := hash.Sum()
+	return fmt.Sprintf("%s%d", name, i+1)
+}
 
-		T = bytes.Add(T, h)
+// Copy of the conf attacking ciphertext i in place of the first
+func (c *Conf) Select(i int) *Conf {
+	d := *c
+	ct := c.Ciphertexts[i]
+	d.L, d.Label, d.C = ct.L, ct.Label, ct.C
+
+	return &d
+}
+
+// Set the label and MGF1 hashes by name. An empty h keeps the label hash,
+// an empty mgf follows h if given and is kept otherwise.
+func (c *Conf) SetHash(h, mgf string) os.Error {
+	switch {
+	case mgf == "" && h == "":
+		mgf = c.MGF
+	case mgf == "":
+		mgf = h
 	}
 
-	return T[0:l], nil
+	if h == "" {
+		h = c.Hash
+	}
+
+	for _, name := range []string{h, mgf} {
+		if _, ok := hashes[name]; !ok {
+			return utils.NewError(fmt.Sprintf("unknown hash '%s'", name))
+		}
+	}
+
+	hLen := hashes[h]().Size()
+	if c.K != nil && c.K.Cmp(big.NewInt(int64(2*hLen+2))) < 0 {
+		return utils.NewError(fmt.Sprintf("%s needs N of at least %d octets", h, 2*hLen+2))
+	}
+
+	c.Hash, c.MGF = h, mgf
+
+	return nil
 }
 
-// Generate Octet string from integer
-func (c *Conf) I2OSP(x int64, l int64) (X []byte, err os.Error) {
-	if x >= int64(math.Pow(256, float64(l))) {
-		return nil, utils.NewError("integer too large")
+// New hash of the label and seed
+func (c *Conf) NewHash() hash.Hash { return hashes[c.Hash]() }
+
+// New hash for MGF1
+func (c *Conf) NewMGFHash() hash.Hash { return hashes[c.MGF]() }
+
+// RSAES-OAEP options for label
+func (c *Conf) Params(label []byte) *pkcs1.Params {
+	return &pkcs1.Params{
+		Hash:  hashes[c.Hash],
+		MGF:   hashes[c.MGF],
+		Label: label,
 	}
+}
 
-	var p int64
-	var n int64
-	X = make([]byte, l)
+// Calculate RSA encryption on f using pk
+func (c *Conf) RSAf(f *big.Int) *big.Int {
+	z := new(big.Int).Exp(f, c.E, c.N)
+
+	z.Mul(z, c.C)
+	z = z.Mod(z, c.N)
 
-	index := 0
-	for i := l - 1; i >= 0; i-- {
-		p = int64(math.Pow(256, float64(i)))
-		n = x / p
-		x = x % p
-		X[index] = byte(n)
-		index++
+	return z
+}
+
+// MGF1 function
+func (c *Conf) MGF1(Z []byte, l int64) (mask []byte, err os.Error) {
+	if l > MAX_LENGTH {
+		return nil, utils.NewError("mask too long")
 	}
 
-	return X, nil
+	return pkcs1.MGF1(c.NewMGFHash, Z, int(l))
+}
+
+// Generate Octet string from integer
+func (c *Conf) I2OSP(x int64, l int64) (X []byte, err os.Error) {
+	return pkcs1.I2OSP(big.NewInt(x), int(l))
 }
 
 // Check message by encrypting with pk and comparing against given cipher
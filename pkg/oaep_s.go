@@ -0,0 +1,301 @@
+///////////////////////////////////////////////////////////
+//                                                       //
+//                 Joshua Van Leeuwen                    //
+//                                                       //
+//                University of Bristol                  //
+//                                                       //
+///////////////////////////////////////////////////////////
+
+package oaep_s
+
+import (
+	"big"
+	"bufio"
+	"bytes"
+	crand "crypto/rand"
+	"crypto/rsa"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"rand"
+	"time"
+
+	"./file"
+	"./oaep_c"
+	"./pkcs1"
+	"./utils"
+)
+
+const (
+	SUCCESS     = '0'
+	ERROR1      = '1'
+	ERROR2      = '2'
+	ERROR_INPUT = '3'
+)
+
+// Simulator of the OAEP decryption target D. Responds to each label and
+// ciphertext with the same codes as D, swapping ERROR1 and ERROR2 with
+// probability Noise. Decoding takes Delay extra nanoseconds, and Uniform
+// targets answer ERROR2 for both errors so only that time tells them apart.
+type Simulator struct {
+	conf *oaep_c.Conf
+	d    *big.Int
+	k    int
+
+	Noise   float64
+	Delay   int64
+	Uniform bool
+
+	rnd *rand.Rand
+}
+
+// Initialise new Simulator with a freshly generated key of bits length
+func NewSimulator(bits int) (*Simulator, os.Error) {
+	priv, err := rsa.GenerateKey(crand.Reader, bits)
+	if err != nil {
+		return nil, utils.Error("failed to generate RSA key", err)
+	}
+
+	return NewSimulatorKey(priv.N, big.NewInt(int64(priv.E)), priv.D), nil
+}
+
+// Initialise new Simulator from a key file of hex lines N, e and d
+func NewSimulatorFile(fileName string) (*Simulator, os.Error) {
+	fr, err := file.NewFileReader(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	var N, e, d *big.Int
+
+	if N, err = fr.ReadInt(); err != nil {
+		return nil, utils.Error("failed to get N", err)
+	}
+
+	if e, err = fr.ReadInt(); err != nil {
+		return nil, utils.Error("failed to get e", err)
+	}
+
+	if d, err = fr.ReadInt(); err != nil {
+		return nil, utils.Error("failed to get d", err)
+	}
+
+	if err := fr.CloseFile(); err != nil {
+		return nil, err
+	}
+
+	return NewSimulatorKey(N, e, d), nil
+}
+
+// Initialise new Simulator from an RSA key
+func NewSimulatorKey(N, e, d *big.Int) *Simulator {
+	k := len(N.Bytes())
+
+	// The target decrypts whether or not 2B < N
+	K, B, _ := oaep_c.Bounds(N)
+
+	return &Simulator{
+		conf: &oaep_c.Conf{
+			N:    N,
+			E:    e,
+			K:    K,
+			B:    B,
+			Hash: oaep_c.SHA1,
+			MGF:  oaep_c.SHA1,
+		},
+		d:   d,
+		k:   k,
+		rnd: rand.New(rand.NewSource(time.Nanoseconds())),
+	}
+}
+
+// Seed the noise source so runs can be reproduced
+func (s *Simulator) Seed(seed int64) { s.rnd = rand.New(rand.NewSource(seed)) }
+
+// Set the label and MGF1 hashes by name, see oaep_c.Conf.SetHash
+func (s *Simulator) SetHash(h, mgf string) os.Error { return s.conf.SetHash(h, mgf) }
+
+// Return the public modulus
+func (s *Simulator) N() *big.Int { return s.conf.N }
+
+// Simulator is in process so there is nothing to start, stop or restart
+func (s *Simulator) Run() os.Error  { return nil }
+func (s *Simulator) Kill() os.Error { return nil }
+func (s *Simulator) Restarts() int  { return 0 }
+
+// Decrypt c with label line l and return the response code of D, which
+// is wrong with probability Noise
+func (s *Simulator) Decrypt(l []byte, c *big.Int) (byte, os.Error) {
+	code, err := s.decrypt(l, c)
+	if err != nil {
+		return 0, err
+	}
+
+	if s.Noise > 0 && s.rnd.Float64() < s.Noise {
+		switch code {
+		case ERROR1:
+			code = ERROR2
+		case ERROR2:
+			code = ERROR1
+		}
+	}
+
+	if s.Uniform && code == ERROR1 {
+		code = ERROR2
+	}
+
+	return code, nil
+}
+
+func (s *Simulator) decrypt(l []byte, c *big.Int) (byte, os.Error) {
+	if c.Cmp(s.conf.N) >= 0 {
+		return ERROR_INPUT, nil
+	}
+
+	label, err := decodeHex(l)
+	if err != nil {
+		return ERROR_INPUT, nil
+	}
+
+	y, err := pkcs1.RSADP(s.conf.N, s.d, c)
+	if err != nil {
+		return ERROR_INPUT, nil
+	}
+
+	if y.Cmp(s.conf.B) >= 0 {
+		return ERROR1, nil
+	}
+
+	em, err := pkcs1.I2OSP(y, s.k)
+	if err != nil {
+		return 0, err
+	}
+
+	return s.decode(em, label)
+}
+
+// EME-OAEP decode em, returning ERROR2 on any decoding error
+func (s *Simulator) decode(em, label []byte) (byte, os.Error) {
+	if s.Delay > 0 {
+		time.Sleep(s.Delay)
+	}
+
+	if _, err := pkcs1.Decode(s.conf.Params(label), s.k, em); err != nil {
+		return ERROR2, nil
+	}
+
+	return SUCCESS, nil
+}
+
+// RSAES-OAEP encrypt m with label l, producing a challenge ciphertext
+func (s *Simulator) Challenge(m, l []byte) (*big.Int, os.Error) {
+	c, err := pkcs1.Encrypt(crand.Reader, s.conf.N, s.conf.E, s.conf.Params(l), m)
+	if err != nil {
+		return nil, err
+	}
+
+	return pkcs1.OS2IP(c), nil
+}
+
+// Write the key as hex lines N, e and d, readable by the owner
+// only
+func (s *Simulator) WriteKey(fileName string) os.Error {
+	fw, err := file.NewFileWriterMode(fileName, 0600)
+	if err != nil {
+		return err
+	}
+
+	for _, z := range []*big.Int{s.conf.N, s.conf.E, s.d} {
+		if err := fw.WriteInt(z); err != nil {
+			return err
+		}
+	}
+
+	return fw.CloseFile()
+}
+
+// Write a keyed attack conf of hex fields N, e and each label of ls and
+// ciphertext of cs, numbered from 2 after the first
+func (s *Simulator) WriteConf(fileName string, ls [][]byte, cs []*big.Int) os.Error {
+	fw, err := file.NewFileWriter(fileName)
+	if err != nil {
+		return err
+	}
+
+	lines := []string{
+		"# oaep attack conf",
+		fmt.Sprintf("N = %X", s.conf.N.Bytes()),
+		fmt.Sprintf("e = %X", s.conf.E.Bytes()),
+	}
+
+	for i := range cs {
+		suffix := ""
+		if i > 0 {
+			suffix = fmt.Sprintf("%d", i+1)
+		}
+
+		lines = utils.Append(lines, fmt.Sprintf("label%s = %X", suffix, ls[i]))
+		lines = utils.Append(lines, fmt.Sprintf("ciphertext%s = %X", suffix, cs[i].Bytes()))
+	}
+
+	lines = utils.Append(lines, fmt.Sprintf("hash = %s", s.conf.Hash))
+	lines = utils.Append(lines, fmt.Sprintf("mgf = %s", s.conf.MGF))
+
+	for _, line := range lines {
+		if err := fw.WriteLine([]byte(line)); err != nil {
+			return err
+		}
+	}
+
+	return fw.CloseFile()
+}
+
+// Answer label and ciphertext line pairs from r on w until r is closed
+func (s *Simulator) Serve(r io.Reader, w io.Writer) os.Error {
+	reader := bufio.NewReader(r)
+
+	for {
+		l, err := reader.ReadBytes('\n')
+		if err == os.EOF {
+			return nil
+		}
+		if err != nil {
+			return utils.Error("failed to read label", err)
+		}
+
+		cb, err := reader.ReadBytes('\n')
+		if err != nil {
+			return utils.Error("failed to read ciphertext", err)
+		}
+
+		code := byte(ERROR_INPUT)
+		c := new(big.Int)
+		if _, ok := c.SetString(string(bytes.TrimSpace(cb)), 16); ok {
+			if code, err = s.Decrypt(l, c); err != nil {
+				return err
+			}
+		}
+
+		if _, err := w.Write([]byte{code, '\n'}); err != nil {
+			return utils.Error("failed to write response code", err)
+		}
+	}
+
+	return nil
+}
+
+// Decode a hex line, ignoring surrounding white space
+func decodeHex(h []byte) ([]byte, os.Error) {
+	h = bytes.TrimSpace(h)
+	if len(h)%2 != 0 {
+		return nil, utils.NewError("odd length hex string")
+	}
+
+	b := make([]byte, len(h)/2)
+	if _, err := hex.Decode(b, h); err != nil {
+		return nil, err
+	}
+
+	return b, nil
+}
@@ -0,0 +1,400 @@
+///////////////////////////////////////////////////////////
+//                                                       //
+//                 Joshua Van Leeuwen                    //
+//                                                       //
+//                University of Bristol                  //
+//                                                       //
+///////////////////////////////////////////////////////////
+
+package oracle
+
+import (
+	"big"
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"./command"
+	"./utils"
+)
+
+// Responses of a padding oracle target
+const (
+	CONFORMING     = '0'
+	NON_CONFORMING = '1'
+)
+
+// Responses of an OAEP decryption target
+const (
+	ERROR1 = '1'
+	ERROR2 = '2'
+)
+
+// Lifecycle of a target, shared by every oracle
+type Target interface {
+	Run() os.Error
+	Kill() os.Error
+	Restarts() int
+}
+
+// RSAES-OAEP decryption oracle. l is the hex encoded label line, the
+// returned code is the first byte of the target response.
+type DecryptOracle interface {
+	Target
+	Decrypt(l []byte, c *big.Int) (code byte, err os.Error)
+}
+
+// RSAES-PKCS1-v1_5 padding oracle, reporting whether c decrypts to a
+// conforming encoding
+type PaddingOracle interface {
+	Target
+	Conforming(c *big.Int) (bool, os.Error)
+}
+
+// Oracle returning the execution time and message of a decryption
+type TimingOracle interface {
+	Target
+	Time(c *big.Int) (t *big.Int, m []byte, err os.Error)
+}
+
+// Oracle encrypting a message with an injected fault specification
+type FaultOracle interface {
+	Target
+	Encrypt(fault []byte, m []byte) (c []byte, err os.Error)
+}
+
+// Oracle returning the power trace and hex ciphertext of a sector encryption
+type PowerOracle interface {
+	Target
+	Trace(block int, sector []byte) (l int, ss []float64, m []byte, err os.Error)
+}
+
+type DecryptCommand struct {
+	command.Process
+	width int
+}
+
+// DecryptOracle telling ERROR1 from ERROR2 by the latency of another, for
+// targets where the two differ only in time. Latencies are the median of
+// reps queries, classified against a threshold set by Calibrate.
+type LatencyOracle struct {
+	DecryptOracle
+
+	reps      int
+	threshold float64
+	slow      byte
+}
+
+type PaddingCommand struct {
+	command.Process
+	width int
+}
+
+type TimingCommand struct {
+	command.Process
+	width int
+}
+
+type FaultCommand struct {
+	command.Process
+}
+
+type PowerCommand struct {
+	command.Process
+	width int
+}
+
+// Initialise new DecryptOracle over a target process. Ciphertexts are
+// padded to width hex characters.
+func NewDecryptCommand(cmd command.Process, width int) *DecryptCommand {
+	return &DecryptCommand{
+		Process: cmd,
+		width:   width,
+	}
+}
+
+// Initialise new LatencyOracle over o, timing reps queries for each
+func NewLatencyOracle(o DecryptOracle, reps int) *LatencyOracle {
+	return &LatencyOracle{
+		DecryptOracle: o,
+		reps:          reps,
+		slow:          ERROR2,
+	}
+}
+
+// Initialise new PaddingOracle over a target process. Ciphertexts are
+// padded to width hex characters.
+func NewPaddingCommand(cmd command.Process, width int) *PaddingCommand {
+	return &PaddingCommand{
+		Process: cmd,
+		width:   width,
+	}
+}
+
+// Initialise new TimingOracle over a target process
+func NewTimingCommand(cmd command.Process, width int) *TimingCommand {
+	return &TimingCommand{
+		Process: cmd,
+		width:   width,
+	}
+}
+
+// Initialise new FaultOracle over a target process
+func NewFaultCommand(cmd command.Process) *FaultCommand {
+	return &FaultCommand{
+		Process: cmd,
+	}
+}
+
+// Initialise new PowerOracle over a target process
+func NewPowerCommand(cmd command.Process, width int) *PowerCommand {
+	return &PowerCommand{
+		Process: cmd,
+		width:   width,
+	}
+}
+
+// Write l and c to D stdin and return the response code
+func (d *DecryptCommand) Decrypt(l []byte, c *big.Int) (byte, os.Error) {
+	n := EncodeInt(c, d.width)
+
+	if err := d.WriteStdin(l); err != nil {
+		return 0, utils.Error("failed to write lable", err)
+	}
+	if err := d.WriteStdin(n); err != nil {
+		return 0, utils.Error("failed to write ciphertext ", err)
+	}
+
+	b, err := d.ReadLines(1)
+	if err != nil {
+		return 0, utils.Error("failed to read stdout file", err)
+	}
+
+	if len(b[0]) == 0 {
+		return 0, utils.NewError("got empty response code")
+	}
+
+	return b[0][0], nil
+}
+
+// Set the threshold between the latencies of ciphertexts known to give
+// ERROR1 and ERROR2, returning the median latency of each and the share
+// of them the threshold classifies correctly
+func (o *LatencyOracle) Calibrate(l []byte, c1, c2 []*big.Int) (t1, t2, accuracy float64, err os.Error) {
+	if len(c1) == 0 || len(c2) == 0 {
+		return 0, 0, 0, utils.NewError("calibration needs ciphertexts of both ERROR1 and ERROR2")
+	}
+
+	var ts1, ts2 []float64
+
+	for _, c := range c1 {
+		t, _, err := o.Latency(l, c)
+		if err != nil {
+			return 0, 0, 0, err
+		}
+		ts1 = utils.AppendFloat(ts1, t)
+	}
+
+	for _, c := range c2 {
+		t, _, err := o.Latency(l, c)
+		if err != nil {
+			return 0, 0, 0, err
+		}
+		ts2 = utils.AppendFloat(ts2, t)
+	}
+
+	t1 = utils.MedianFloat(ts1)
+	t2 = utils.MedianFloat(ts2)
+
+	o.threshold = (t1 + t2) / 2
+	o.slow = ERROR2
+	if t1 > t2 {
+		o.slow = ERROR1
+	}
+
+	right := 0
+	for _, t := range ts1 {
+		if o.classify(t) == ERROR1 {
+			right++
+		}
+	}
+	for _, t := range ts2 {
+		if o.classify(t) == ERROR2 {
+			right++
+		}
+	}
+
+	return t1, t2, float64(right) / float64(len(ts1)+len(ts2)), nil
+}
+
+// Decrypt l and c, classifying ERROR1 and ERROR2 responses by latency.
+// Other codes are returned as given.
+func (o *LatencyOracle) Decrypt(l []byte, c *big.Int) (byte, os.Error) {
+	t, code, err := o.Latency(l, c)
+	if err != nil {
+		return 0, err
+	}
+
+	if code != ERROR1 && code != ERROR2 {
+		return code, nil
+	}
+
+	return o.classify(t), nil
+}
+
+// Median latency in nanoseconds of reps decryptions of l and c, and the
+// last code given
+func (o *LatencyOracle) Latency(l []byte, c *big.Int) (float64, byte, os.Error) {
+	ts := make([]float64, o.reps)
+	var code byte
+
+	for i := range ts {
+		now := time.Nanoseconds()
+
+		var err os.Error
+		if code, err = o.DecryptOracle.Decrypt(l, c); err != nil {
+			return 0, 0, err
+		}
+
+		ts[i] = float64(time.Nanoseconds() - now)
+	}
+
+	return utils.MedianFloat(ts), code, nil
+}
+
+// Code of a response taking t nanoseconds
+func (o *LatencyOracle) classify(t float64) byte {
+	if (t >= o.threshold) == (o.slow == ERROR2) {
+		return ERROR2
+	}
+
+	return ERROR1
+}
+
+// Write c to D stdin and return whether its padding conforms
+func (p *PaddingCommand) Conforming(c *big.Int) (bool, os.Error) {
+	if err := p.WriteStdin(EncodeInt(c, p.width)); err != nil {
+		return false, utils.Error("failed to write ciphertext ", err)
+	}
+
+	b, err := p.ReadLines(1)
+	if err != nil {
+		return false, utils.Error("failed to read stdout file", err)
+	}
+
+	if len(b[0]) == 0 {
+		return false, utils.NewError("got empty response code")
+	}
+
+	switch b[0][0] {
+	case CONFORMING:
+		return true, nil
+	case NON_CONFORMING:
+		return false, nil
+	}
+
+	return false, utils.NewError(fmt.Sprintf("got bad response code '%c'", b[0][0]))
+}
+
+// Write c to D stdin and read back the time and message
+func (t *TimingCommand) Time(c *big.Int) (*big.Int, []byte, os.Error) {
+	if err := t.WriteStdin(EncodeInt(c, t.width)); err != nil {
+		return nil, nil, utils.Error("failed to write ciphertext ", err)
+	}
+
+	b, err := t.ReadLines(2)
+	if err != nil {
+		return nil, nil, utils.Error("failed to read stdout file", err)
+	}
+
+	tt, err := utils.BytesToInt(b[0])
+	if err != nil {
+		return nil, nil, utils.Error("failed to convert time bytes", err)
+	}
+
+	return tt, b[1], nil
+}
+
+// Write the fault and message to D stdin and read back the cipher text
+func (f *FaultCommand) Encrypt(fault []byte, message []byte) ([]byte, os.Error) {
+	m := make([]byte, len(message)*2)
+	hex.Encode(m, message)
+	m = bytes.AddByte(m, '\n')
+
+	if err := f.WriteStdin(fault); err != nil {
+		return nil, utils.Error("failed to write fault", err)
+	}
+	if err := f.WriteStdin(m); err != nil {
+		return nil, utils.Error("failed to write message", err)
+	}
+
+	b, err := f.ReadLines(1)
+	if err != nil {
+		return nil, utils.Error("failed to read cipher text", err)
+	}
+
+	c := bytes.TrimSpace(b[0])
+	if len(c)%2 != 0 {
+		c = utils.AppendByteSlice([]byte{'0'}, c)
+	}
+
+	ct := make([]byte, len(c)/2)
+	if _, err := hex.Decode(ct, c); err != nil {
+		return nil, utils.Error("failed to decode cipher text", err)
+	}
+
+	return ct, nil
+}
+
+// Write the block and sector address to D stdin and read back the trace
+func (p *PowerCommand) Trace(block int, sector []byte) (int, []float64, []byte, os.Error) {
+	i := make([]byte, len(sector)*2)
+	hex.Encode(i, sector)
+	i = utils.Pad(bytes.AddByte(i, '\n'), p.width)
+	j := bytes.AddByte(utils.IntToBytes(block), '\n')
+
+	if err := p.WriteStdin(j); err != nil {
+		return -1, nil, nil, utils.Error("failed to write block adress", err)
+	}
+
+	if err := p.WriteStdin(i); err != nil {
+		return -1, nil, nil, utils.Error("failed to write sector address", err)
+	}
+
+	return p.read()
+}
+
+// Read the trace length, samples and cipher text from D stdout
+func (p *PowerCommand) read() (int, []float64, []byte, os.Error) {
+	b, err := p.ReadLines(2)
+	if err != nil {
+		return -1, nil, nil, utils.Error("failed to read power consumption", err)
+	}
+
+	str := strings.Split(string(b[0]), ",", 0)
+	l, err := strconv.Atoi(str[0])
+	if err != nil {
+		return -1, nil, nil, utils.Error("failed to convert power length integer string", err)
+	}
+
+	ss := make([]float64, len(str)-1)
+	for i := range ss {
+		if ss[i], err = strconv.Atof64(str[i+1]); err != nil {
+			return -1, nil, nil, utils.Error("failed to convert power sample", err)
+		}
+	}
+
+	return l, ss, b[1], nil
+}
+
+// Convert c to padded hex bytes with CR
+func EncodeInt(c *big.Int, width int) []byte {
+	n := make([]byte, len(c.Bytes())*2)
+	hex.Encode(n, c.Bytes())
+
+	return utils.Pad(bytes.AddByte(n, '\n'), width)
+}
@@ -0,0 +1,360 @@
+///////////////////////////////////////////////////////////
+//                                                       //
+//                 Joshua Van Leeuwen                    //
+//                                                       //
+//                University of Bristol                  //
+//                                                       //
+///////////////////////////////////////////////////////////
+
+package pkcs1
+
+import (
+	"big"
+	"bytes"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+
+	"./utils"
+)
+
+// RSAES-OAEP options, as in PKCS#1 v2.2 section 7.1
+type Params struct {
+	Hash  func() hash.Hash // label and seed
+	MGF   func() hash.Hash // MGF1
+	Label []byte
+}
+
+// Shortest padding string of EME-PKCS1-v1_5
+const PS_MIN = 8
+
+// Every decoding failure gives the same error so callers cannot become an
+// oracle by accident
+var decryptionError = utils.NewError("decryption error")
+
+// Convert non negative x to an octet string of length l
+func I2OSP(x *big.Int, l int) ([]byte, os.Error) {
+	b := x.Bytes()
+	if x.Sign() < 0 || len(b) > l {
+		return nil, utils.NewError("integer too large")
+	}
+
+	X := make([]byte, l)
+	copy(X[l-len(b):], b)
+
+	return X, nil
+}
+
+// Convert an octet string to a non negative integer
+func OS2IP(X []byte) *big.Int { return new(big.Int).SetBytes(X) }
+
+// RSA encryption primitive, m^e mod N
+func RSAEP(N, e, m *big.Int) (*big.Int, os.Error) {
+	if m.Sign() < 0 || m.Cmp(N) >= 0 {
+		return nil, utils.NewError("message representative out of range")
+	}
+
+	return new(big.Int).Exp(m, e, N), nil
+}
+
+// RSA decryption primitive, c^d mod N
+func RSADP(N, d, c *big.Int) (*big.Int, os.Error) {
+	if c.Sign() < 0 || c.Cmp(N) >= 0 {
+		return nil, utils.NewError("ciphertext representative out of range")
+	}
+
+	return new(big.Int).Exp(c, d, N), nil
+}
+
+// Mask generation function of length l from seed Z
+func MGF1(h func() hash.Hash, Z []byte, l int) ([]byte, os.Error) {
+	hLen := h().Size()
+	if int64(l) > int64(hLen)<<32 {
+		return nil, utils.NewError("mask too long")
+	}
+
+	var T []byte
+	for i := 0; len(T) < l; i++ {
+		C, err := I2OSP(big.NewInt(int64(i)), 4)
+		if err != nil {
+			return nil, err
+		}
+
+		hash := h()
+		hash.Write(Z)
+		hash.Write(C)
+		T = utils.AppendByteSlice(T, hash.Sum())
+	}
+
+	return T[0:l], nil
+}
+
+// EME-OAEP encode M into k octets with a random seed from rand
+func Encode(rand io.Reader, p *Params, k int, M []byte) ([]byte, os.Error) {
+	seed := make([]byte, p.Hash().Size())
+	if _, err := io.ReadFull(rand, seed); err != nil {
+		return nil, utils.Error("failed to generate seed", err)
+	}
+
+	return EncodeSeed(p, k, M, seed)
+}
+
+// EME-OAEP encode M into k octets with the given seed
+func EncodeSeed(p *Params, k int, M, seed []byte) ([]byte, os.Error) {
+	hash := p.Hash()
+	hLen := hash.Size()
+
+	if len(seed) != hLen {
+		return nil, utils.NewError(fmt.Sprintf("expected seed of %d octets, got %d", hLen, len(seed)))
+	}
+
+	if len(M) > k-2*hLen-2 {
+		return nil, utils.NewError("message too long")
+	}
+
+	hash.Write(p.Label)
+
+	// DB = lHash || PS || 0x01 || M
+	DB := make([]byte, k-hLen-1)
+	copy(DB, hash.Sum())
+	DB[len(DB)-len(M)-1] = 1
+	copy(DB[len(DB)-len(M):], M)
+
+	dbMask, err := MGF1(p.MGF, seed, len(DB))
+	if err != nil {
+		return nil, err
+	}
+	maskedDB := utils.XOR(DB, dbMask)
+
+	seedMask, err := MGF1(p.MGF, maskedDB, hLen)
+	if err != nil {
+		return nil, err
+	}
+	maskedSeed := utils.XOR(seed, seedMask)
+
+	// EM = 0x00 || maskedSeed || maskedDB
+	EM := make([]byte, 1, k)
+	EM = utils.AppendByteSlice(EM, maskedSeed)
+
+	return utils.AppendByteSlice(EM, maskedDB), nil
+}
+
+// EME-OAEP decode the k octets of EM
+func Decode(p *Params, k int, EM []byte) ([]byte, os.Error) {
+	hash := p.Hash()
+	hLen := hash.Size()
+
+	if k < 2*hLen+2 || len(EM) != k {
+		return nil, decryptionError
+	}
+
+	hash.Write(p.Label)
+	lHash := hash.Sum()
+
+	maskedSeed := EM[1 : 1+hLen]
+	maskedDB := EM[1+hLen:]
+
+	seedMask, err := MGF1(p.MGF, maskedDB, hLen)
+	if err != nil {
+		return nil, decryptionError
+	}
+	seed := utils.XOR(maskedSeed, seedMask)
+
+	dbMask, err := MGF1(p.MGF, seed, len(maskedDB))
+	if err != nil {
+		return nil, decryptionError
+	}
+	DB := utils.XOR(maskedDB, dbMask)
+
+	// Skip the zero padding up to the 0x01 separator
+	i := hLen
+	for i < len(DB) && DB[i] == 0 {
+		i++
+	}
+
+	if EM[0] != 0 || !bytes.Equal(lHash, DB[0:hLen]) || i == len(DB) || DB[i] != 1 {
+		return nil, decryptionError
+	}
+
+	return DB[i+1:], nil
+}
+
+// RSAES-OAEP encrypt M under public key N and e, giving a ciphertext of
+// the octet length of N
+func Encrypt(rand io.Reader, N, e *big.Int, p *Params, M []byte) ([]byte, os.Error) {
+	k := len(N.Bytes())
+
+	EM, err := Encode(rand, p, k, M)
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := RSAEP(N, e, OS2IP(EM))
+	if err != nil {
+		return nil, err
+	}
+
+	return I2OSP(c, k)
+}
+
+// RSAES-OAEP decrypt C under private key N and d
+func Decrypt(N, d *big.Int, p *Params, C []byte) ([]byte, os.Error) {
+	k := len(N.Bytes())
+	if len(C) != k {
+		return nil, decryptionError
+	}
+
+	m, err := RSADP(N, d, OS2IP(C))
+	if err != nil {
+		return nil, decryptionError
+	}
+
+	EM, err := I2OSP(m, k)
+	if err != nil {
+		return nil, decryptionError
+	}
+
+	return Decode(p, k, EM)
+}
+
+// EME-PKCS1-v1_5 encode M into k octets with random non zero padding
+func EncodeV15(rand io.Reader, k int, M []byte) ([]byte, os.Error) {
+	if len(M) > k-PS_MIN-3 {
+		return nil, utils.NewError("message too long")
+	}
+
+	// EM = 0x00 || 0x02 || PS || 0x00 || M
+	EM := make([]byte, k)
+	EM[1] = 2
+	copy(EM[k-len(M):], M)
+
+	PS := EM[2 : k-len(M)-1]
+	if _, err := io.ReadFull(rand, PS); err != nil {
+		return nil, utils.Error("failed to generate padding", err)
+	}
+
+	for i := range PS {
+		for PS[i] == 0 {
+			if _, err := io.ReadFull(rand, PS[i:i+1]); err != nil {
+				return nil, utils.Error("failed to generate padding", err)
+			}
+		}
+	}
+
+	return EM, nil
+}
+
+// EME-PKCS1-v1_5 decode the k octets of EM
+func DecodeV15(k int, EM []byte) ([]byte, os.Error) {
+	if k < PS_MIN+3 || len(EM) != k || EM[0] != 0 || EM[1] != 2 {
+		return nil, decryptionError
+	}
+
+	i := bytes.IndexByte(EM[2:], 0)
+	if i < PS_MIN {
+		return nil, decryptionError
+	}
+
+	return EM[2+i+1:], nil
+}
+
+// RSAES-PKCS1-v1_5 encrypt M under public key N and e
+func EncryptV15(rand io.Reader, N, e *big.Int, M []byte) ([]byte, os.Error) {
+	k := len(N.Bytes())
+
+	EM, err := EncodeV15(rand, k, M)
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := RSAEP(N, e, OS2IP(EM))
+	if err != nil {
+		return nil, err
+	}
+
+	return I2OSP(c, k)
+}
+
+// RSAES-PKCS1-v1_5 decrypt C under private key N and d
+func DecryptV15(N, d *big.Int, C []byte) ([]byte, os.Error) {
+	k := len(N.Bytes())
+	if len(C) != k {
+		return nil, decryptionError
+	}
+
+	m, err := RSADP(N, d, OS2IP(C))
+	if err != nil {
+		return nil, decryptionError
+	}
+
+	EM, err := I2OSP(m, k)
+	if err != nil {
+		return nil, decryptionError
+	}
+
+	return DecodeV15(k, EM)
+}
+
+// DER encoded DigestInfo prefixes of SHA-1 and SHA-2 digests, told apart
+// by their length, as in PKCS#1 v2.2 section 9.2
+var digestInfos = map[int][]byte{
+	20: []byte{0x30, 0x21, 0x30, 0x09, 0x06, 0x05, 0x2B, 0x0E, 0x03, 0x02, 0x1A, 0x05, 0x00, 0x04, 0x14},
+	28: []byte{0x30, 0x2D, 0x30, 0x0D, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x04, 0x05, 0x00, 0x04, 0x1C},
+	32: []byte{0x30, 0x31, 0x30, 0x0D, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x01, 0x05, 0x00, 0x04, 0x20},
+	48: []byte{0x30, 0x41, 0x30, 0x0D, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x02, 0x05, 0x00, 0x04, 0x30},
+	64: []byte{0x30, 0x51, 0x30, 0x0D, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x03, 0x05, 0x00, 0x04, 0x40},
+}
+
+// EMSA-PKCS1-v1_5 encode a SHA-1 or SHA-2 digest into k octets
+func EncodeSignature(digest []byte, k int) ([]byte, os.Error) {
+	prefix, ok := digestInfos[len(digest)]
+	if !ok {
+		return nil, utils.NewError(fmt.Sprintf("no SHA-1 or SHA-2 digest of %d octets", len(digest)))
+	}
+
+	tLen := len(prefix) + len(digest)
+	if k < tLen+PS_MIN+3 {
+		return nil, utils.NewError("intended encoded message length too short")
+	}
+
+	// EM = 0x00 || 0x01 || PS || 0x00 || T
+	EM := make([]byte, k)
+	EM[1] = 1
+	for i := 2; i < k-tLen-1; i++ {
+		EM[i] = 0xFF
+	}
+	copy(EM[k-tLen:], prefix)
+	copy(EM[k-len(digest):], digest)
+
+	return EM, nil
+}
+
+// RSASSA-PKCS1-v1_5 verify signature S of digest under public key N and e
+func VerifySignature(N, e *big.Int, digest, S []byte) os.Error {
+	k := len(N.Bytes())
+	if len(S) != k {
+		return utils.NewError("invalid signature")
+	}
+
+	m, err := RSAEP(N, e, OS2IP(S))
+	if err != nil {
+		return utils.NewError("invalid signature")
+	}
+
+	EM, err := I2OSP(m, k)
+	if err != nil {
+		return utils.NewError("invalid signature")
+	}
+
+	exp, err := EncodeSignature(digest, k)
+	if err != nil {
+		return err
+	}
+
+	if !bytes.Equal(EM, exp) {
+		return utils.NewError("invalid signature")
+	}
+
+	return nil
+}
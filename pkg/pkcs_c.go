@@ -0,0 +1,96 @@
+///////////////////////////////////////////////////////////
+//                                                       //
+//                 Joshua Van Leeuwen                    //
+//                                                       //
+//                University of Bristol                  //
+//                                                       //
+///////////////////////////////////////////////////////////
+
+package pkcs_c
+
+import (
+	"big"
+	"os"
+
+	"./config"
+	"./pkcs1"
+	"./utils"
+)
+
+var (
+	// Fields of a keyed conf, and the order of a legacy one
+	FIELDS = []string{"n", "e", "ciphertext", "encoding"}
+	LEGACY = []string{"n", "e", "ciphertext"}
+)
+
+type Conf struct {
+	N *big.Int
+	E *big.Int
+	C *big.Int
+
+	K *big.Int
+	B *big.Int
+}
+
+// Initialise new PKCS#1 v1.5 Conf struct
+func NewConf(fileName string) (*Conf, os.Error) {
+	cfg, err := config.NewConfig(fileName, FIELDS, LEGACY)
+	if err != nil {
+		return nil, err
+	}
+
+	conf := new(Conf)
+
+	if conf.N, _, err = cfg.Int("n"); err != nil {
+		return nil, err
+	}
+
+	if conf.E, _, err = cfg.Int("e"); err != nil {
+		return nil, err
+	}
+
+	if conf.C, _, err = cfg.Int("ciphertext"); err != nil {
+		return nil, err
+	}
+
+	k := len(conf.N.Bytes())
+
+	switch {
+	case k < pkcs1.PS_MIN+3:
+		return nil, cfg.Error("n", "N too short for PKCS#1 v1.5 padding")
+	case conf.E.Cmp(big.NewInt(1)) <= 0 || conf.E.Cmp(conf.N) >= 0:
+		return nil, cfg.Error("e", "expected 1 < e < N")
+	case conf.C.Cmp(conf.N) >= 0:
+		return nil, cfg.Error("ciphertext", "expected ciphertext < N")
+	}
+
+	conf.K = big.NewInt(int64(k))
+
+	// B = 2^(8(k-2))
+	conf.B = new(big.Int).Sub(conf.K, big.NewInt(2))
+	conf.B.Mul(conf.B, big.NewInt(8))
+	conf.B.Exp(big.NewInt(2), conf.B, nil)
+
+	return conf, nil
+}
+
+// Calculate RSA encryption on s using pk, blinding the ciphertext
+func (c *Conf) RSAf(s *big.Int) *big.Int {
+	z := new(big.Int).Exp(s, c.E, c.N)
+
+	z.Mul(z, c.C)
+	z = z.Mod(z, c.N)
+
+	return z
+}
+
+// Check message by encrypting with pk and comparing against given cipher
+func (c *Conf) CheckMessage(m *big.Int) os.Error {
+	m_c := new(big.Int).Exp(m, c.E, c.N)
+
+	if m_c.Cmp(c.C) != 0 {
+		return utils.NewError("calculated message cipher and given cipher texts don't match.")
+	}
+
+	return nil
+}
@@ -0,0 +1,204 @@
+///////////////////////////////////////////////////////////
+//                                                       //
+//                 Joshua Van Leeuwen                    //
+//                                                       //
+//                University of Bristol                  //
+//                                                       //
+///////////////////////////////////////////////////////////
+
+package pkcs_s
+
+import (
+	"big"
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"fmt"
+	"io"
+	"os"
+
+	"./file"
+	"./pkcs1"
+	"./utils"
+)
+
+const (
+	CONFORMING     = '0'
+	NON_CONFORMING = '1'
+	ERROR_INPUT    = '2'
+)
+
+// Simulator of a PKCS#1 v1.5 decryption target. Strict targets check the
+// whole encoding, lenient ones only its leading 00 02.
+type Simulator struct {
+	N *big.Int
+	E *big.Int
+
+	d      *big.Int
+	k      int
+	strict bool
+}
+
+// Initialise new Simulator with a freshly generated key of bits length
+func NewSimulator(bits int) (*Simulator, os.Error) {
+	priv, err := rsa.GenerateKey(rand.Reader, bits)
+	if err != nil {
+		return nil, utils.Error("failed to generate RSA key", err)
+	}
+
+	return NewSimulatorKey(priv.N, big.NewInt(int64(priv.E)), priv.D), nil
+}
+
+// Initialise new Simulator from a key file of hex lines N, e and d
+func NewSimulatorFile(fileName string) (*Simulator, os.Error) {
+	fr, err := file.NewFileReader(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	var N, e, d *big.Int
+
+	if N, err = fr.ReadInt(); err != nil {
+		return nil, utils.Error("failed to get N", err)
+	}
+
+	if e, err = fr.ReadInt(); err != nil {
+		return nil, utils.Error("failed to get e", err)
+	}
+
+	if d, err = fr.ReadInt(); err != nil {
+		return nil, utils.Error("failed to get d", err)
+	}
+
+	if err := fr.CloseFile(); err != nil {
+		return nil, err
+	}
+
+	return NewSimulatorKey(N, e, d), nil
+}
+
+// Initialise new strict Simulator from an RSA key
+func NewSimulatorKey(N, e, d *big.Int) *Simulator {
+	return &Simulator{
+		N:      N,
+		E:      e,
+		d:      d,
+		k:      len(N.Bytes()),
+		strict: true,
+	}
+}
+
+// Check the whole encoding if strict, else only its leading 00 02
+func (s *Simulator) SetStrict(strict bool) { s.strict = strict }
+
+// Simulator is in process so there is nothing to start, stop or restart
+func (s *Simulator) Run() os.Error  { return nil }
+func (s *Simulator) Kill() os.Error { return nil }
+func (s *Simulator) Restarts() int  { return 0 }
+
+// Decrypt c and return whether its encoding conforms
+func (s *Simulator) Conforming(c *big.Int) (bool, os.Error) {
+	if c.Cmp(s.N) >= 0 {
+		return false, utils.NewError("ciphertext out of range")
+	}
+
+	em, err := pkcs1.I2OSP(new(big.Int).Exp(c, s.d, s.N), s.k)
+	if err != nil {
+		return false, err
+	}
+
+	if !s.strict {
+		return em[0] == 0 && em[1] == 2, nil
+	}
+
+	_, err = pkcs1.DecodeV15(s.k, em)
+
+	return err == nil, nil
+}
+
+// RSAES-PKCS1-v1_5 encrypt m, producing a challenge ciphertext
+func (s *Simulator) Challenge(m []byte) (*big.Int, os.Error) {
+	c, err := pkcs1.EncryptV15(rand.Reader, s.N, s.E, m)
+	if err != nil {
+		return nil, err
+	}
+
+	return pkcs1.OS2IP(c), nil
+}
+
+// Write the key as hex lines N, e and d, readable by the owner
+// only
+func (s *Simulator) WriteKey(fileName string) os.Error {
+	fw, err := file.NewFileWriterMode(fileName, 0600)
+	if err != nil {
+		return err
+	}
+
+	for _, z := range []*big.Int{s.N, s.E, s.d} {
+		if err := fw.WriteInt(z); err != nil {
+			return err
+		}
+	}
+
+	return fw.CloseFile()
+}
+
+// Write a keyed attack conf of hex fields N, e and ciphertext
+func (s *Simulator) WriteConf(fileName string, c *big.Int) os.Error {
+	fw, err := file.NewFileWriter(fileName)
+	if err != nil {
+		return err
+	}
+
+	lines := []string{
+		"# pkcs attack conf",
+		fmt.Sprintf("N = %X", s.N.Bytes()),
+		fmt.Sprintf("e = %X", s.E.Bytes()),
+		fmt.Sprintf("ciphertext = %X", c.Bytes()),
+	}
+
+	for _, line := range lines {
+		if err := fw.WriteLine([]byte(line)); err != nil {
+			return err
+		}
+	}
+
+	return fw.CloseFile()
+}
+
+// Answer ciphertext lines from r with a response code line on w until r
+// is closed
+func (s *Simulator) Serve(r io.Reader, w io.Writer) os.Error {
+	reader := bufio.NewReader(r)
+
+	for {
+		cb, err := reader.ReadBytes('\n')
+		if err == os.EOF {
+			return nil
+		}
+		if err != nil {
+			return utils.Error("failed to read ciphertext", err)
+		}
+
+		code := byte(ERROR_INPUT)
+		c := new(big.Int)
+		if _, ok := c.SetString(string(bytes.TrimSpace(cb)), 16); ok && c.Cmp(s.N) < 0 {
+			ok, err := s.Conforming(c)
+			if err != nil {
+				return err
+			}
+
+			code = NON_CONFORMING
+			if ok {
+				code = CONFORMING
+			}
+		}
+
+		if _, err := w.Write([]byte{code, '\n'}); err != nil {
+			return utils.Error("failed to write response code", err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,109 @@
+///////////////////////////////////////////////////////////
+//                                                       //
+//                 Joshua Van Leeuwen                    //
+//                                                       //
+//                University of Bristol                  //
+//                                                       //
+///////////////////////////////////////////////////////////
+
+package pool
+
+import (
+	"fmt"
+	"os"
+
+	"./oracle"
+	"./utils"
+)
+
+// Pool of oracles over separate copies of a target. Jobs are handed the
+// next free oracle so each copy only ever serves one job at a time.
+type Pool struct {
+	targets []oracle.Target
+	free    chan oracle.Target
+}
+
+// Result of a job
+type result struct {
+	i   int
+	err os.Error
+}
+
+// Initialise new Pool over targets
+func NewPool(targets []oracle.Target) *Pool {
+	free := make(chan oracle.Target, len(targets))
+	for _, t := range targets {
+		free <- t
+	}
+
+	return &Pool{
+		targets: targets,
+		free:    free,
+	}
+}
+
+// Number of targets in the pool
+func (p *Pool) Len() int { return len(p.targets) }
+
+// Run every target
+func (p *Pool) Run() os.Error {
+	for _, t := range p.targets {
+		if err := t.Run(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Kill every target, returning the first error
+func (p *Pool) Kill() (err os.Error) {
+	for _, t := range p.targets {
+		if e := t.Kill(); e != nil && err == nil {
+			err = e
+		}
+	}
+
+	return err
+}
+
+// Restarts summed over every target
+func (p *Pool) Restarts() int {
+	n := 0
+	for _, t := range p.targets {
+		n += t.Restarts()
+	}
+
+	return n
+}
+
+// Run job for i from 0 to n-1 concurrently over the free targets. Jobs
+// should store their results at i so order is kept. Returns the error of
+// the lowest failing i once every job has finished.
+func (p *Pool) Do(n int, job func(t oracle.Target, i int) os.Error) os.Error {
+	resCh := make(chan *result, n)
+
+	for i := 0; i < n; i++ {
+		go func(i int) {
+			t := <-p.free
+			err := job(t, i)
+			p.free <- t
+
+			resCh <- &result{i, err}
+		}(i)
+	}
+
+	var first *result
+	for k := 0; k < n; k++ {
+		res := <-resCh
+		if res.err != nil && (first == nil || res.i < first.i) {
+			first = res
+		}
+	}
+
+	if first != nil {
+		return utils.Error(fmt.Sprintf("job %d failed", first.i), first.err)
+	}
+
+	return nil
+}
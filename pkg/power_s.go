@@ -0,0 +1,285 @@
+///////////////////////////////////////////////////////////
+//                                                       //
+//                 Joshua Van Leeuwen                    //
+//                                                       //
+//                University of Bristol                  //
+//                                                       //
+///////////////////////////////////////////////////////////
+
+package power_s
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	crand "crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"rand"
+	"strconv"
+	"time"
+
+	"./rijndael"
+	"./utils"
+)
+
+const (
+	BLOCK_SIZE = rijndael.BLOCK_SIZE
+	ROUNDS     = rijndael.ROUNDS
+
+	SAMPLES_PER_OP = 4
+	IDLE_SAMPLES   = 200
+	BASELINE       = 100
+	SCALE          = 10
+)
+
+// Simulator of the power target D. Decrypts the stored block with
+// AES-XTS under the secret k1 and k2, returning a trace built from the
+// Hamming weight of every SubBytes output of the tweak encryption and the
+// block decryption.
+type Simulator struct {
+	k1   []byte
+	k2   []byte
+	Data []byte
+
+	Length int
+	Noise  float64
+	Jitter int
+
+	rk1  [][]byte
+	rk2  [][]byte
+	aes1 *aes.Cipher
+
+	rnd *rand.Rand
+}
+
+// Initialise new Simulator with random keys
+func NewSimulator() (*Simulator, os.Error) {
+	k1 := make([]byte, BLOCK_SIZE)
+	k2 := make([]byte, BLOCK_SIZE)
+
+	if _, err := io.ReadFull(crand.Reader, k1); err != nil {
+		return nil, utils.Error("failed to generate k1", err)
+	}
+	if _, err := io.ReadFull(crand.Reader, k2); err != nil {
+		return nil, utils.Error("failed to generate k2", err)
+	}
+
+	return NewSimulatorKey(k1, k2)
+}
+
+// Initialise new Simulator with 16 byte keys k1 and k2
+func NewSimulatorKey(k1, k2 []byte) (*Simulator, os.Error) {
+	if len(k1) != BLOCK_SIZE || len(k2) != BLOCK_SIZE {
+		return nil, utils.NewError(fmt.Sprintf("expected keys of %d bytes, got=%d and %d", BLOCK_SIZE, len(k1), len(k2)))
+	}
+
+	aes1, err := aes.NewCipher(k1)
+	if err != nil {
+		return nil, utils.Error("failed to create new AES cipher for k1", err)
+	}
+
+	s := &Simulator{
+		k1:     k1,
+		k2:     k2,
+		Data:   make([]byte, BLOCK_SIZE),
+		Length: 20000,
+		aes1:   aes1,
+		rnd:    rand.New(rand.NewSource(time.Nanoseconds())),
+	}
+
+	s.rk1 = rijndael.ExpandKey(k1)
+	s.rk2 = rijndael.ExpandKey(k2)
+
+	return s, nil
+}
+
+// Return the keys k1 and k2
+func (s *Simulator) Keys() ([]byte, []byte) { return s.k1, s.k2 }
+
+// Seed the noise and jitter source so runs can be reproduced
+func (s *Simulator) Seed(seed int64) { s.rnd = rand.New(rand.NewSource(seed)) }
+
+// Simulator is in process so there is nothing to start, stop or restart
+func (s *Simulator) Run() os.Error  { return nil }
+func (s *Simulator) Kill() os.Error { return nil }
+func (s *Simulator) Restarts() int  { return 0 }
+
+// Decrypt the stored block at block and sector address, returning the
+// trace and the hex plaintext
+func (s *Simulator) Trace(block int, sector []byte) (int, []float64, []byte, os.Error) {
+	if len(sector) > BLOCK_SIZE {
+		return -1, nil, nil, utils.NewError(fmt.Sprintf("sector address longer than %d bytes", BLOCK_SIZE))
+	}
+
+	i := make([]byte, BLOCK_SIZE)
+	copy(i[BLOCK_SIZE-len(sector):], sector)
+
+	// T = AES-k2(i) * a^j
+	T, tweakOps := s.encrypt(s.rk2, i)
+	for j := 0; j < block; j++ {
+		mulAlpha(T)
+	}
+
+	// P = AES-k1^-1(C ^ T) ^ T
+	pp := make([]byte, BLOCK_SIZE)
+	s.aes1.Decrypt(utils.XOR(s.Data, T), pp)
+	m := utils.XOR(pp, T)
+
+	// The states of the decryption are those of encrypting its output,
+	// in reverse order
+	_, decOps := s.encrypt(s.rk1, pp)
+	for l, r := 0, len(decOps)-1; l < r; l, r = l+1, r-1 {
+		decOps[l], decOps[r] = decOps[r], decOps[l]
+	}
+
+	ss := s.trace(tweakOps, decOps)
+
+	return len(ss), ss, []byte(fmt.Sprintf("%X", m)), nil
+}
+
+// Build a trace of Length samples with the tweak at the start and the
+// decryption at the end, each shifted by up to Jitter samples
+func (s *Simulator) trace(start, end [][]byte) []float64 {
+	ss := make([]float64, s.Length)
+	for i := range ss {
+		ss[i] = s.sample(BASELINE)
+	}
+
+	offset := IDLE_SAMPLES
+	if s.Jitter > 0 {
+		offset += s.rnd.Intn(s.Jitter + 1)
+	}
+	s.leak(ss, offset, start)
+
+	offset = s.Length - IDLE_SAMPLES - len(end)*BLOCK_SIZE*SAMPLES_PER_OP
+	if s.Jitter > 0 {
+		offset -= s.rnd.Intn(s.Jitter + 1)
+	}
+	s.leak(ss, offset, end)
+
+	return ss
+}
+
+// Write the Hamming weight of each operation into ss from offset
+func (s *Simulator) leak(ss []float64, offset int, ops [][]byte) {
+	for _, op := range ops {
+		for _, b := range op {
+			hw := float64(utils.HammingWeight(b))
+
+			for k := 0; k < SAMPLES_PER_OP; k++ {
+				if offset >= 0 && offset < len(ss) {
+					ss[offset] = s.sample(BASELINE + SCALE*hw)
+				}
+				offset++
+			}
+		}
+	}
+}
+
+// Add noise to a sample, which is kept a positive integer
+func (s *Simulator) sample(v float64) float64 {
+	v += s.rnd.NormFloat64() * s.Noise
+	if v < 1 {
+		return 1
+	}
+
+	return float64(int64(v))
+}
+
+// Encrypt m under the round keys rk, returning the SubBytes output of
+// every round
+func (s *Simulator) encrypt(rk [][]byte, m []byte) ([]byte, [][]byte) {
+	state := make([]byte, BLOCK_SIZE)
+	copy(state, m)
+
+	ops := make([][]byte, ROUNDS)
+
+	rijndael.AddRoundKey(state, rk[0])
+
+	for r := 1; r <= ROUNDS; r++ {
+		rijndael.SubBytes(state)
+
+		ops[r-1] = make([]byte, BLOCK_SIZE)
+		copy(ops[r-1], state)
+
+		rijndael.ShiftRows(state)
+		if r != ROUNDS {
+			rijndael.MixColumns(state)
+		}
+		rijndael.AddRoundKey(state, rk[r])
+	}
+
+	return state, ops
+}
+
+// Answer block and sector address line pairs from r with a trace line and
+// plaintext line on w until r is closed
+func (s *Simulator) Serve(r io.Reader, w io.Writer) os.Error {
+	reader := bufio.NewReader(r)
+
+	for {
+		jb, err := reader.ReadBytes('\n')
+		if err == os.EOF {
+			return nil
+		}
+		if err != nil {
+			return utils.Error("failed to read block address", err)
+		}
+
+		ib, err := reader.ReadBytes('\n')
+		if err != nil {
+			return utils.Error("failed to read sector address", err)
+		}
+
+		j, err := strconv.Atoi(string(bytes.TrimSpace(jb)))
+		if err != nil {
+			return utils.Error("failed to parse block address", err)
+		}
+
+		ib = bytes.TrimSpace(ib)
+		if len(ib)%2 != 0 {
+			ib = utils.AppendByteSlice([]byte{'0'}, ib)
+		}
+
+		i := make([]byte, len(ib)/2)
+		if _, err := hex.Decode(i, ib); err != nil {
+			return utils.Error("failed to decode sector address", err)
+		}
+
+		l, ss, m, err := s.Trace(j, i)
+		if err != nil {
+			return err
+		}
+
+		// Written in one go so the trace arrives in full reads
+		buf := new(bytes.Buffer)
+		fmt.Fprintf(buf, "%d", l)
+		for _, v := range ss {
+			fmt.Fprintf(buf, ",%d", int64(v))
+		}
+		fmt.Fprintf(buf, "\n%s\n", m)
+
+		if _, err := w.Write(buf.Bytes()); err != nil {
+			return utils.Error("failed to write trace", err)
+		}
+	}
+
+	return nil
+}
+
+// Multiply a tweak by the primitive element a of GF(2^128)
+func mulAlpha(T []byte) {
+	carry := T[BLOCK_SIZE-1] >> 7
+
+	for k := BLOCK_SIZE - 1; k > 0; k-- {
+		T[k] = (T[k] << 1) | (T[k-1] >> 7)
+	}
+	T[0] <<= 1
+
+	if carry == 1 {
+		T[0] ^= 0x87
+	}
+}
@@ -0,0 +1,161 @@
+///////////////////////////////////////////////////////////
+//                                                       //
+//                 Joshua Van Leeuwen                    //
+//                                                       //
+//                University of Bristol                  //
+//                                                       //
+///////////////////////////////////////////////////////////
+
+package report
+
+import (
+	"big"
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"time"
+
+	"./utils"
+)
+
+// Results of an attack run, written as a JSON document. Values are kept
+// in the order they are set. A nil Report records nothing.
+type Report struct {
+	attack string
+	start  int64
+
+	names  []string
+	values map[string]string
+}
+
+// Initialise new Report for attack, starting its clock
+func NewReport(attack string) *Report {
+	return &Report{
+		attack: attack,
+		start:  time.Nanoseconds(),
+		values: make(map[string]string),
+	}
+}
+
+// Record v as name, replacing any earlier value. v may be a string, bool,
+// int, int64, float64, []byte or *big.Int, or a slice of int, float64,
+// []byte or *big.Int. Octets and integers are written as hex strings.
+func (r *Report) Set(name string, v interface{}) {
+	if r == nil {
+		return
+	}
+
+	if _, ok := r.values[name]; !ok {
+		r.names = utils.Append(r.names, name)
+	}
+
+	r.values[name] = encode(v)
+}
+
+// JSON value recorded as name, empty if none was
+func (r *Report) Get(name string) string {
+	if r == nil {
+		return ""
+	}
+
+	return r.values[name]
+}
+
+// Write the report to w, with success and error taken from err
+func (r *Report) Write(w io.Writer, err os.Error) os.Error {
+	var b bytes.Buffer
+
+	fmt.Fprintf(&b, "{\n  \"attack\": %s,\n", encode(r.attack))
+	fmt.Fprintf(&b, "  \"success\": %s,\n", encode(err == nil))
+	if err != nil {
+		fmt.Fprintf(&b, "  \"error\": %s,\n", encode(err.String()))
+	}
+	fmt.Fprintf(&b, "  \"duration\": %s", encode(float64(time.Nanoseconds()-r.start)/1e9))
+
+	for _, name := range r.names {
+		fmt.Fprintf(&b, ",\n  %s: %s", encode(name), r.values[name])
+	}
+	b.WriteString("\n}\n")
+
+	if _, err := w.Write(b.Bytes()); err != nil {
+		return utils.Error("failed to write report", err)
+	}
+
+	return nil
+}
+
+// Encode v as a JSON value
+func encode(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return strconv.Quote(v)
+	case bool:
+		return strconv.Btoa(v)
+	case int:
+		return strconv.Itoa(v)
+	case int64:
+		return strconv.Itoa64(v)
+	case float64:
+		// JSON has no NaN or infinities
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return "null"
+		}
+		return strconv.Ftoa64(v, 'g', -1)
+	case []byte:
+		return strconv.Quote(fmt.Sprintf("%X", v))
+	case *big.Int:
+		if v == nil {
+			return "null"
+		}
+		return strconv.Quote(fmt.Sprintf("%X", v.Bytes()))
+	case []int:
+		s := make([]string, len(v))
+		for i := range v {
+			s[i] = encode(v[i])
+		}
+		return list(s)
+	case []float64:
+		s := make([]string, len(v))
+		for i := range v {
+			s[i] = encode(v[i])
+		}
+		return list(s)
+	case [][]byte:
+		s := make([]string, len(v))
+		for i := range v {
+			// Missing results are null
+			s[i] = "null"
+			if v[i] != nil {
+				s[i] = encode(v[i])
+			}
+		}
+		return list(s)
+	case []*big.Int:
+		s := make([]string, len(v))
+		for i := range v {
+			s[i] = encode(v[i])
+		}
+		return list(s)
+	}
+
+	return "null"
+}
+
+// Join encoded values as a JSON array
+func list(s []string) string {
+	var b bytes.Buffer
+
+	b.WriteString("[")
+	for i := range s {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString(s[i])
+	}
+	b.WriteString("]")
+
+	return b.String()
+}
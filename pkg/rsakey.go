@@ -0,0 +1,230 @@
+///////////////////////////////////////////////////////////
+//                                                       //
+//                 Joshua Van Leeuwen                    //
+//                                                       //
+//                University of Bristol                  //
+//                                                       //
+///////////////////////////////////////////////////////////
+
+package rsakey
+
+import (
+	"big"
+	"bytes"
+	"encoding/pem"
+	"fmt"
+	"os"
+
+	"./file"
+	"./utils"
+)
+
+const (
+	// Random bases tried when factoring N from d
+	FACTOR_TRIES = 100
+
+	PKCS1_TYPE = "RSA PRIVATE KEY"
+	PKCS8_TYPE = "PRIVATE KEY"
+)
+
+var (
+	// DER AlgorithmIdentifier of rsaEncryption, OID 1.2.840.113549.1.1.1
+	// with NULL parameters
+	rsaEncryption = []byte{0x30, 0x0D, 0x06, 0x09, 0x2A, 0x86, 0x48, 0x86, 0xF7, 0x0D, 0x01, 0x01, 0x01, 0x05, 0x00}
+)
+
+// Full RSA private key with its CRT values
+type Key struct {
+	N *big.Int
+	E *big.Int
+	D *big.Int
+
+	// p < q
+	P *big.Int
+	Q *big.Int
+
+	// d mod p-1, d mod q-1 and q^-1 mod p
+	DP   *big.Int
+	DQ   *big.Int
+	QInv *big.Int
+}
+
+// Reconstruct the key of N, e and d, factoring N. ed - 1 = 2^t r is a
+// multiple of lcm(p-1, q-1), so for most g some g^(2^i r) is a square
+// root of 1 other than +-1, and shares a factor with N once less one.
+func NewKey(N, e, d *big.Int) (*Key, os.Error) {
+	one := big.NewInt(1)
+	two := big.NewInt(2)
+	N1 := new(big.Int).Sub(N, one)
+
+	// ed - 1 = 2^t r with r odd
+	r := new(big.Int).Mul(e, d)
+	r.Sub(r, one)
+	if r.Sign() <= 0 {
+		return nil, utils.NewError("expected ed > 1")
+	}
+
+	t := 0
+	for new(big.Int).And(r, one).Sign() == 0 {
+		r.Rsh(r, 1)
+		t++
+	}
+
+	if t == 0 {
+		return nil, utils.NewError("ed - 1 is odd, d does not match e")
+	}
+
+	k := int64(len(N.Bytes()))
+
+	for i := 0; i < FACTOR_TRIES; i++ {
+		// 2 <= g < N
+		g := utils.RandInt(256, k)
+		g.Mod(g, N)
+		if g.Cmp(two) < 0 {
+			continue
+		}
+
+		y := new(big.Int).Exp(g, r, N)
+		if y.Cmp(one) == 0 || y.Cmp(N1) == 0 {
+			continue
+		}
+
+		for j := 0; j < t; j++ {
+			x := new(big.Int).Exp(y, two, N)
+
+			if x.Cmp(one) == 0 {
+				// y is a non trivial square root of 1
+				p := new(big.Int)
+				big.GcdInt(p, new(big.Int), new(big.Int), y.Sub(y, one), N)
+
+				q, _ := new(big.Int).Div(N, p)
+
+				key, err := NewKeyPrimes(e, p, q)
+				if err != nil {
+					return nil, err
+				}
+
+				// Keep the recovered d, which may differ from e^-1 mod
+				// (p-1)(q-1) by a multiple of lcm(p-1, q-1)
+				key.D = d
+
+				return key, nil
+			}
+
+			if x.Cmp(N1) == 0 {
+				break
+			}
+
+			y = x
+		}
+	}
+
+	return nil, utils.NewError(fmt.Sprintf("no factor of N from d after %d tries, d may not match e", FACTOR_TRIES))
+}
+
+// Reconstruct the key of e and the primes p and q of N
+func NewKeyPrimes(e, p, q *big.Int) (*Key, os.Error) {
+	one := big.NewInt(1)
+
+	if p.Cmp(q) > 0 {
+		p, q = q, p
+	}
+
+	p1 := new(big.Int).Sub(p, one)
+	q1 := new(big.Int).Sub(q, one)
+	phi := new(big.Int).Mul(p1, q1)
+
+	g := new(big.Int)
+	big.GcdInt(g, new(big.Int), new(big.Int), e, phi)
+	if g.Cmp(one) != 0 {
+		return nil, utils.NewError("e is not invertible mod (p-1)(q-1)")
+	}
+
+	d := utils.ModInverse(e, phi)
+
+	return &Key{
+			N:    new(big.Int).Mul(p, q),
+			E:    e,
+			D:    d,
+			P:    p,
+			Q:    q,
+			DP:   new(big.Int).Mod(d, p1),
+			DQ:   new(big.Int).Mod(d, q1),
+			QInv: utils.ModInverse(q, p),
+		},
+		nil
+}
+
+// DER RSAPrivateKey of PKCS#1
+func (k *Key) PKCS1() []byte {
+	var b bytes.Buffer
+
+	b.Write(derInt(big.NewInt(0)))
+	for _, z := range []*big.Int{k.N, k.E, k.D, k.P, k.Q, k.DP, k.DQ, k.QInv} {
+		b.Write(derInt(z))
+	}
+
+	return tlv(0x30, b.Bytes())
+}
+
+// DER PrivateKeyInfo of PKCS#8, wrapping the PKCS#1 key
+func (k *Key) PKCS8() []byte {
+	var b bytes.Buffer
+
+	b.Write(derInt(big.NewInt(0)))
+	b.Write(rsaEncryption)
+	b.Write(tlv(0x04, k.PKCS1()))
+
+	return tlv(0x30, b.Bytes())
+}
+
+// Write the key to fileName as a PEM block of PKCS#8 if pkcs8, else of
+// PKCS#1
+func (k *Key) WritePEM(fileName string, pkcs8 bool) os.Error {
+	block := &pem.Block{Type: PKCS1_TYPE, Bytes: k.PKCS1()}
+	if pkcs8 {
+		block = &pem.Block{Type: PKCS8_TYPE, Bytes: k.PKCS8()}
+	}
+
+	// Readable by the owner only
+	fw, err := file.NewFileWriterMode(fileName, 0600)
+	if err != nil {
+		return err
+	}
+
+	if err := pem.Encode(fw, block); err != nil {
+		return utils.Error("failed to encode key", err)
+	}
+
+	return fw.CloseFile()
+}
+
+// DER INTEGER of non negative z
+func derInt(z *big.Int) []byte {
+	b := z.Bytes()
+
+	// Zero is one octet, and a set top bit would read as negative
+	if len(b) == 0 || b[0]&0x80 != 0 {
+		b = utils.AppendByteSlice([]byte{0}, b)
+	}
+
+	return tlv(0x02, b)
+}
+
+// DER tag, length and value
+func tlv(tag byte, value []byte) []byte {
+	var b bytes.Buffer
+	b.WriteByte(tag)
+
+	if len(value) < 0x80 {
+		b.WriteByte(byte(len(value)))
+	} else {
+		l := big.NewInt(int64(len(value))).Bytes()
+		b.WriteByte(0x80 | byte(len(l)))
+		b.Write(l)
+	}
+
+	b.Write(value)
+
+	return b.Bytes()
+}
@@ -12,8 +12,13 @@ import (
 	"big"
 	"os"
 
-	"./file"
-	"./utils"
+	"./config"
+)
+
+var (
+	// Fields of a keyed conf, and the order of a legacy one
+	FIELDS = []string{"n", "e", "encoding"}
+	LEGACY = []string{"n", "e"}
 )
 
 type Conf struct {
@@ -23,19 +28,23 @@ type Conf struct {
 
 // Initialise new Time Conf struct
 func NewConf(fileName string) (*Conf, os.Error) {
-	fr, err := file.NewFileReader(fileName)
+	cfg, err := config.NewConfig(fileName, FIELDS, LEGACY)
 	if err != nil {
 		return nil, err
 	}
 
 	conf := new(Conf)
 
-	if conf.N, err = fr.ReadInt(); err != nil {
-		return nil, utils.Error("failed to get N", err)
+	if conf.N, _, err = cfg.Int("n"); err != nil {
+		return nil, err
+	}
+
+	if conf.E, _, err = cfg.Int("e"); err != nil {
+		return nil, err
 	}
 
-	if conf.E, err = fr.ReadInt(); err != nil {
-		return nil, utils.Error("failed to get e", err)
+	if conf.E.Cmp(big.NewInt(1)) <= 0 || conf.E.Cmp(conf.N) >= 0 {
+		return nil, cfg.Error("e", "expected 1 < e < N")
 	}
 
 	return conf, nil
@@ -0,0 +1,230 @@
+///////////////////////////////////////////////////////////
+//                                                       //
+//                 Joshua Van Leeuwen                    //
+//                                                       //
+//                University of Bristol                  //
+//                                                       //
+///////////////////////////////////////////////////////////
+
+package time_s
+
+import (
+	"big"
+	"bufio"
+	"bytes"
+	crand "crypto/rand"
+	"crypto/rsa"
+	"fmt"
+	"io"
+	"os"
+	"rand"
+	"time"
+
+	"./file"
+	"./montgomery"
+	"./utils"
+)
+
+const (
+	BASE_CYCLES = 10000
+	MUL_CYCLES  = 1000
+	RED_CYCLES  = 100
+)
+
+// Simulator of the timing target D. Decrypts each ciphertext with a
+// Montgomery exponentiation and reports the cycles spent, where every
+// extra reduction costs RED_CYCLES plus Gaussian noise of Noise cycles.
+type Simulator struct {
+	N *big.Int
+	E *big.Int
+	d *big.Int
+
+	Noise  float64
+	Window int
+
+	mnt *montgomery.Montgomery
+	rnd *rand.Rand
+}
+
+// Initialise new Simulator with a fresh modulus of bits length and a
+// private exponent of dbits length
+func NewSimulator(bits, dbits int) (*Simulator, os.Error) {
+	priv, err := rsa.GenerateKey(crand.Reader, bits)
+	if err != nil {
+		return nil, utils.Error("failed to generate RSA primes", err)
+	}
+
+	one := big.NewInt(1)
+	p := new(big.Int).Sub(priv.P, one)
+	q := new(big.Int).Sub(priv.Q, one)
+	phi := new(big.Int).Mul(p, q)
+
+	g := new(big.Int)
+	e := new(big.Int)
+	y := new(big.Int)
+
+	for {
+		d := utils.RandInt(2, int64(dbits-1))
+		d.Add(d, new(big.Int).Exp(big.NewInt(2), big.NewInt(int64(dbits-1)), nil))
+
+		big.GcdInt(g, e, y, d, phi)
+		if g.Cmp(one) != 0 {
+			continue
+		}
+
+		if e.Cmp(big.NewInt(0)) < 0 {
+			e.Add(e, phi)
+		}
+
+		return NewSimulatorKey(priv.N, e, d), nil
+	}
+
+	return nil, nil
+}
+
+// Initialise new Simulator from a key file of hex lines N, e and d
+func NewSimulatorFile(fileName string) (*Simulator, os.Error) {
+	fr, err := file.NewFileReader(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	var N, e, d *big.Int
+
+	if N, err = fr.ReadInt(); err != nil {
+		return nil, utils.Error("failed to get N", err)
+	}
+
+	if e, err = fr.ReadInt(); err != nil {
+		return nil, utils.Error("failed to get e", err)
+	}
+
+	if d, err = fr.ReadInt(); err != nil {
+		return nil, utils.Error("failed to get d", err)
+	}
+
+	if err := fr.CloseFile(); err != nil {
+		return nil, err
+	}
+
+	return NewSimulatorKey(N, e, d), nil
+}
+
+// Initialise new Simulator from an RSA key
+func NewSimulatorKey(N, e, d *big.Int) *Simulator {
+	return &Simulator{
+		N:      N,
+		E:      e,
+		d:      d,
+		Window: 1,
+		mnt:    montgomery.NewMontgomery(N),
+		rnd:    rand.New(rand.NewSource(time.Nanoseconds())),
+	}
+}
+
+// Seed the noise source so runs can be reproduced
+func (s *Simulator) Seed(seed int64) { s.rnd = rand.New(rand.NewSource(seed)) }
+
+// Simulator is in process so there is nothing to start, stop or restart
+func (s *Simulator) Run() os.Error  { return nil }
+func (s *Simulator) Kill() os.Error { return nil }
+func (s *Simulator) Restarts() int  { return 0 }
+
+// Decrypt c, returning the cycles spent and the message
+func (s *Simulator) Time(c *big.Int) (*big.Int, []byte, os.Error) {
+	c = new(big.Int).Mod(c, s.N)
+
+	t_hat, reds := s.mnt.ExpRed(c, s.d, s.Window)
+	m, _ := s.mnt.Mul(t_hat, big.NewInt(1))
+
+	cycles := float64(BASE_CYCLES + reds*RED_CYCLES)
+	cycles += float64(MUL_CYCLES * s.muls())
+	cycles += s.rnd.NormFloat64() * s.Noise
+	if cycles < 0 {
+		cycles = 0
+	}
+
+	return big.NewInt(int64(cycles)), []byte(fmt.Sprintf("%X", m.Bytes())), nil
+}
+
+// Number of multiplications of a square and multiply over d. This is
+// constant for a key, only reductions depend on the ciphertext.
+func (s *Simulator) muls() int {
+	n := 0
+	for _, b := range s.d.Bytes() {
+		n += 8 + int(utils.HammingWeight(b))
+	}
+
+	return n
+}
+
+// Write the key as hex lines N, e and d, readable by the owner
+// only
+func (s *Simulator) WriteKey(fileName string) os.Error {
+	fw, err := file.NewFileWriterMode(fileName, 0600)
+	if err != nil {
+		return err
+	}
+
+	for _, z := range []*big.Int{s.N, s.E, s.d} {
+		if err := fw.WriteInt(z); err != nil {
+			return err
+		}
+	}
+
+	return fw.CloseFile()
+}
+
+// Write a keyed attack conf of hex fields N and e
+func (s *Simulator) WriteConf(fileName string) os.Error {
+	fw, err := file.NewFileWriter(fileName)
+	if err != nil {
+		return err
+	}
+
+	lines := []string{
+		"# time attack conf",
+		fmt.Sprintf("N = %X", s.N.Bytes()),
+		fmt.Sprintf("e = %X", s.E.Bytes()),
+	}
+
+	for _, line := range lines {
+		if err := fw.WriteLine([]byte(line)); err != nil {
+			return err
+		}
+	}
+
+	return fw.CloseFile()
+}
+
+// Answer ciphertext lines from r with time and message lines on w until r
+// is closed
+func (s *Simulator) Serve(r io.Reader, w io.Writer) os.Error {
+	reader := bufio.NewReader(r)
+
+	for {
+		cb, err := reader.ReadBytes('\n')
+		if err == os.EOF {
+			return nil
+		}
+		if err != nil {
+			return utils.Error("failed to read ciphertext", err)
+		}
+
+		c := new(big.Int)
+		if _, ok := c.SetString(string(bytes.TrimSpace(cb)), 16); !ok {
+			return utils.NewError(fmt.Sprintf("failed to parse ciphertext '%s'", bytes.TrimSpace(cb)))
+		}
+
+		t, m, err := s.Time(c)
+		if err != nil {
+			return err
+		}
+
+		if _, err := fmt.Fprintf(w, "%s\n%s\n", t.String(), m); err != nil {
+			return utils.Error("failed to write time and message", err)
+		}
+	}
+
+	return nil
+}
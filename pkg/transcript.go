@@ -0,0 +1,292 @@
+///////////////////////////////////////////////////////////
+//                                                       //
+//                 Joshua Van Leeuwen                    //
+//                                                       //
+//                University of Bristol                  //
+//                                                       //
+///////////////////////////////////////////////////////////
+
+package transcript
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"./command"
+	"./file"
+	"./utils"
+)
+
+const (
+	RECORD_ENV = "ATTACK_RECORD"
+	REPLAY_ENV = "ATTACK_REPLAY"
+
+	WRITE = '>'
+	READ  = '<'
+)
+
+var (
+	// Transcript files to record to or replay from, set by the -record and
+	// -replay flags. RECORD_ENV and REPLAY_ENV are read when empty.
+	RecordFile string
+	ReplayFile string
+)
+
+// Process recording every write and read of another to a transcript file.
+// Each line holds the time in nanoseconds, the interaction counter, the
+// direction and the bytes in hex.
+type Recorder struct {
+	command.Process
+
+	fw           *file.FileWriter
+	interactions int
+	reading      bool
+}
+
+// Process answering reads from a transcript instead of a target
+type Player struct {
+	fileName string
+	seed     int64
+
+	entries [][]byte
+	next    int
+}
+
+// Initialise the Process for a target binary. Wraps it in a Recorder when
+// there is a file to record to, or replays a transcript in its place.
+func NewProcess(target string) (command.Process, os.Error) {
+	if fileName := setting(ReplayFile, REPLAY_ENV); fileName != "" {
+		p, err := NewPlayer(fileName)
+		if err != nil {
+			return nil, err
+		}
+
+		utils.Seed(p.seed)
+
+		return p, nil
+	}
+
+	cmd, err := command.NewCommand(target)
+	if err != nil {
+		return nil, err
+	}
+
+	if fileName := setting(RecordFile, RECORD_ENV); fileName != "" {
+		return NewRecorder(cmd, fileName, utils.RandSeed())
+	}
+
+	return cmd, nil
+}
+
+// Initialise n Processes for copies of a target binary. Transcripts can
+// only be kept of a single target since the order of a pool is not fixed.
+func NewProcesses(target string, n int) ([]command.Process, os.Error) {
+	if n > 1 && (setting(RecordFile, RECORD_ENV) != "" || setting(ReplayFile, REPLAY_ENV) != "") {
+		return nil, utils.NewError(fmt.Sprintf("can not record or replay a pool of %d targets", n))
+	}
+
+	ps := make([]command.Process, n)
+	for i := range ps {
+		p, err := NewProcess(target)
+		if err != nil {
+			return nil, err
+		}
+		ps[i] = p
+	}
+
+	return ps, nil
+}
+
+// Transcript file of a flag, falling back to the environment variable env
+func setting(flag, env string) string {
+	if flag != "" {
+		return flag
+	}
+
+	return os.Getenv(env)
+}
+
+// Initialise new Recorder of p, writing to fileName. The seed of the run
+// is kept in the header so a replay generates the same requests.
+func NewRecorder(p command.Process, fileName string, seed int64) (*Recorder, os.Error) {
+	fw, err := file.NewFileWriter(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := fw.WriteLine([]byte(fmt.Sprintf("# seed %d", seed))); err != nil {
+		return nil, err
+	}
+
+	return &Recorder{
+			Process: p,
+			fw:      fw,
+		},
+		nil
+}
+
+// Write bytes to the process stdin and record them. The first write after
+// a read starts a new interaction.
+func (r *Recorder) WriteStdin(b []byte) os.Error {
+	if r.reading || r.interactions == 0 {
+		r.interactions++
+		r.reading = false
+	}
+
+	if err := r.Process.WriteStdin(b); err != nil {
+		return err
+	}
+
+	return r.record(WRITE, b)
+}
+
+// Read n lines from the process stdout and record them as one response
+func (r *Recorder) ReadLines(n int) ([][]byte, os.Error) {
+	b, err := r.Process.ReadLines(n)
+	if err != nil {
+		return nil, err
+	}
+
+	r.reading = true
+
+	return b, r.record(READ, bytes.Join(b, []byte{'\n'}))
+}
+
+// Kill the process and close the transcript
+func (r *Recorder) Kill() os.Error {
+	if err := r.Process.Kill(); err != nil {
+		return err
+	}
+
+	return r.fw.CloseFile()
+}
+
+// Write a transcript line
+func (r *Recorder) record(dir byte, b []byte) os.Error {
+	h := make([]byte, len(b)*2)
+	hex.Encode(h, b)
+
+	line := fmt.Sprintf("%d %d %c %s", time.Nanoseconds(), r.interactions, dir, h)
+	if err := r.fw.WriteLine([]byte(line)); err != nil {
+		return utils.Error("failed to record transcript", err)
+	}
+
+	return nil
+}
+
+// Initialise new Player of the transcript fileName
+func NewPlayer(fileName string) (*Player, os.Error) {
+	fr, err := file.NewFileReader(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	lines, err := fr.ReadLines()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := fr.CloseFile(); err != nil {
+		return nil, err
+	}
+
+	p := &Player{
+		fileName: fileName,
+	}
+
+	for _, line := range lines {
+		if len(line) == 0 {
+			continue
+		}
+
+		if line[0] != '#' {
+			p.entries = utils.AppendByte2(p.entries, line)
+			continue
+		}
+
+		fields := bytes.Fields(line)
+		if len(fields) == 3 && string(fields[1]) == "seed" {
+			if p.seed, err = strconv.Atoi64(string(fields[2])); err != nil {
+				return nil, utils.Error(fmt.Sprintf("bad seed in transcript '%s'", fileName), err)
+			}
+		}
+	}
+
+	return p, nil
+}
+
+// Nothing to run, kill or restart when replaying
+func (p *Player) Run() os.Error  { return nil }
+func (p *Player) Kill() os.Error { return nil }
+func (p *Player) Restarts() int  { return 0 }
+
+// Check b is the next recorded write
+func (p *Player) WriteStdin(b []byte) os.Error {
+	n, data, err := p.entry(WRITE)
+	if err != nil {
+		return err
+	}
+
+	if !bytes.Equal(b, data) {
+		return utils.NewError(fmt.Sprintf("transcript '%s' diverged at interaction %d, exp=%q got=%q", p.fileName, n, data, b))
+	}
+
+	return nil
+}
+
+// Return the lines of the next recorded read
+func (p *Player) ReadLines(n int) ([][]byte, os.Error) {
+	i, data, err := p.entry(READ)
+	if err != nil {
+		return nil, err
+	}
+
+	b := [][]byte{data}
+	if n > 1 {
+		b = bytes.Split(data, []byte{'\n'}, 0)
+	}
+
+	if len(b) != n {
+		return nil, utils.NewError(fmt.Sprintf("transcript '%s' diverged at interaction %d, exp=%d lines got=%d", p.fileName, i, n, len(b)))
+	}
+
+	return b, nil
+}
+
+// Return the interaction counter and bytes of the next entry, which must
+// be in direction dir
+func (p *Player) entry(dir byte) (int, []byte, os.Error) {
+	if p.next >= len(p.entries) {
+		return -1, nil, utils.NewError(fmt.Sprintf("transcript '%s' exhausted after %d entries", p.fileName, p.next))
+	}
+
+	fields := bytes.Fields(p.entries[p.next])
+	p.next++
+
+	if len(fields) < 3 || len(fields[2]) != 1 {
+		return -1, nil, utils.NewError(fmt.Sprintf("malformed transcript '%s' entry %d", p.fileName, p.next))
+	}
+
+	n, err := strconv.Atoi(string(fields[1]))
+	if err != nil {
+		return -1, nil, utils.Error(fmt.Sprintf("bad interaction counter in transcript '%s'", p.fileName), err)
+	}
+
+	if fields[2][0] != dir {
+		return n, nil, utils.NewError(fmt.Sprintf("transcript '%s' diverged at interaction %d, exp=%c got=%c", p.fileName, n, dir, fields[2][0]))
+	}
+
+	var data []byte
+	if len(fields) > 3 {
+		data = make([]byte, len(fields[3])/2)
+		if _, err := hex.Decode(data, fields[3]); err != nil {
+			return n, nil, utils.Error(fmt.Sprintf("bad data in transcript '%s'", p.fileName), err)
+		}
+	}
+
+	return n, data, nil
+}
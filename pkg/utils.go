@@ -14,6 +14,8 @@ import (
 	"rand"
 	"os"
 	"encoding/binary"
+	"flag"
+	"sort"
 	"time"
 )
 
@@ -22,6 +24,11 @@ const (
 	BASE        = 16
 )
 
+var (
+	seed = time.Nanoseconds()
+	rnd  = rand.New(rand.NewSource(seed))
+)
+
 type WaitGroup struct {
 	n      int
 	stopCh chan struct{}
@@ -40,12 +47,13 @@ func Fatal(err os.Error) {
 	os.Exit(1)
 }
 
-// Function for checking CLI arguments
-func ParseArguments() ([]string, os.Error) {
+// Function for checking the n CLI arguments left after flags. Relative
+// paths are made absolute.
+func ParseArguments(n int) ([]string, os.Error) {
 	var args []string
 
-	if len(os.Args) < 2 || len(os.Args) > 3 {
-		return nil, NewError(fmt.Sprintf("expected 1 or 2 argmuents, got=%d", len(os.Args)-1))
+	if flag.NArg() != n {
+		return nil, NewError(fmt.Sprintf("expected %d argmuents, got=%d", n, flag.NArg()))
 	}
 
 	wd, err := os.Getwd()
@@ -53,8 +61,15 @@ func ParseArguments() ([]string, os.Error) {
 		return nil, Error("failed to get current working directory", err)
 	}
 
-	for i := 1; i < len(os.Args); i++ {
-		args = Append(args, fmt.Sprintf("%s/%s", wd, os.Args[i]))
+	for i, arg := range flag.Args() {
+		if len(arg) == 0 {
+			return nil, NewError(fmt.Sprintf("expected a path for argument %d, got an empty string", i+1))
+		}
+
+		if arg[0] != '/' {
+			arg = fmt.Sprintf("%s/%s", wd, arg)
+		}
+		args = Append(args, arg)
 	}
 
 	return args, nil
@@ -112,20 +127,6 @@ func HammingWeight(v byte) byte {
 	return (v + (v >> 4)) & 0xF
 }
 
-// Trim byte slice left of white space
-func TrimLeft(bytes []byte) []byte {
-	s, e := 0, len(bytes)-1
-
-	for bytes[s] == 48 || bytes[s] == 0 {
-		s++
-	}
-	for bytes[e] == 0 {
-		e--
-	}
-
-	return bytes[s : e+1]
-}
-
 // Ceiling division on big.Int
 func CeilingDiv(x *big.Int, y *big.Int) *big.Int {
 	z := new(big.Int)
@@ -247,13 +248,29 @@ func IntToBytes(i int) []byte {
 	return b
 }
 
+// Seed the source of RandInt so runs can be reproduced
+func Seed(s int64) {
+	seed = s
+	rnd = rand.New(rand.NewSource(s))
+}
+
+// Return the seed of the RandInt source
+func RandSeed() int64 { return seed }
+
+// Return a new source seeded from that of RandInt, for a goroutine to
+// draw from in place of RandInt, which is not safe for concurrent use.
+// Sources made in a fixed order keep a seeded run reproducible.
+func NewRand() *rand.Rand { return rand.New(rand.NewSource(rnd.Int63())) }
+
 // Generate a random big.Int of byte length x**e
-func RandInt(x, e int64) *big.Int {
-	rnd := rand.New(rand.NewSource(time.Nanoseconds()))
+func RandInt(x, e int64) *big.Int { return RandIntFrom(rnd, x, e) }
+
+// Generate a random big.Int of byte length x**e from the source r
+func RandIntFrom(r *rand.Rand, x, e int64) *big.Int {
 	n := new(big.Int).Exp(big.NewInt(x), big.NewInt(e), nil)
 	b := make([]byte, len(n.Bytes()))
 	for i := range b {
-		b[i] = byte(rnd.Intn(255))
+		b[i] = byte(r.Intn(255))
 	}
 
 	z := new(big.Int).SetBytes(b)
@@ -322,6 +339,36 @@ func AverageFloat(zs []float64) float64 {
 	return z / float64(len(zs))
 }
 
+// Calculate the median float from a slice of floats
+func MedianFloat(zs []float64) float64 {
+	if len(zs) == 0 {
+		return 0
+	}
+
+	s := make([]float64, len(zs))
+	copy(s, zs)
+	sort.SortFloat64s(s)
+
+	if len(s)%2 == 0 {
+		return (s[len(s)/2-1] + s[len(s)/2]) / 2
+	}
+
+	return s[len(s)/2]
+}
+
+// Calculate the inverse of x mod N
+func ModInverse(x, N *big.Int) *big.Int {
+	g := new(big.Int)
+	y := new(big.Int)
+	big.GcdInt(g, y, new(big.Int), x, N)
+
+	if y.Sign() < 0 {
+		y.Add(y, N)
+	}
+
+	return y
+}
+
 // Convert big.Int to float64
 func BigIntToFloat(z *big.Int) float64 {
 	b := z.Bytes()
@@ -6,23 +6,26 @@
 //                                                       //
 ///////////////////////////////////////////////////////////
 
-package main
+package power_a
 
 import (
 	"big"
 	"bytes"
 	"crypto/aes"
 	"encoding/hex"
+	"flag"
 	"fmt"
 	"math"
 	"os"
 	"runtime"
-	"strconv"
-	"strings"
+	"sync"
 	"time"
 
-	"./command"
+	"./oracle"
+	"./pool"
 	"./power_c"
+	"./report"
+	"./transcript"
 	"./utils"
 )
 
@@ -34,18 +37,29 @@ const (
 )
 
 var (
-	SAMPLES   = 20
-	TRACE_NUM = 3000
-	CHUNKS    = TRACE_NUM / CHUNKSIZE
+	traces   *int
+	window   *int
+	poolSize *int
 )
 
 type Attack struct {
-	cmd  *command.Command
-	conf *power_c.Conf
+	oracle oracle.PowerOracle
+	pool   *pool.Pool
+	conf   *power_c.Conf
+	report *report.Report
 
 	samples *Samples
 
 	interactions int
+	mu           sync.Mutex
+
+	samplesN int
+	traceNum int
+	chunks   int
+
+	// Maximum correlation of each key byte
+	k1Corrs []float64
+	k2Corrs []float64
 }
 
 type Samples struct {
@@ -60,37 +74,58 @@ type Samples struct {
 	CC [][]float64
 }
 
-func main() {
+// Register the attack flags
+func Flags() {
+	traces = flag.Int("traces", 20, "number of traces to start with")
+	window = flag.Int("window", 3000, "number of samples of each trace to correlate against")
+	poolSize = flag.Int("pool", 1, "number of copies of the target to gather traces from")
+}
 
+// Run the attack on the target in args, recording the results in r
+func Main(args []string, r *report.Report) os.Error {
 	fmt.Printf("Initialising attack...")
-	a, err := NewAttack()
+	a, err := NewAttack(args, *traces, *window, *poolSize)
 	if err != nil {
-		utils.Fatal(err)
+		return err
 	}
 	fmt.Printf("done.\n")
 
 	runtime.GOMAXPROCS(1)
 
-	if err := a.Run(); err != nil {
-		utils.Fatal(err)
-	}
+	a.report = r
+	err = a.Run()
+
+	r.Set("interactions", a.interactions)
+	r.Set("restarts", a.pool.Restarts())
+
+	return err
 }
 
-func NewAttack() (*Attack, os.Error) {
-	args, err := utils.ParseArguments()
-	if err != nil {
-		return nil, err
+// Initialise new attack struct, gathering traces correlated over window
+// samples from n copies of the target
+func NewAttack(args []string, traces, window, n int) (*Attack, os.Error) {
+	if traces < 1 || window < CHUNKSIZE || n < 1 {
+		return nil, utils.NewError(fmt.Sprintf("expected at least 1 trace, a window of %d samples and 1 target, got traces=%d window=%d pool=%d", CHUNKSIZE, traces, window, n))
 	}
 
-	cmd, err := command.NewCommand(args[0])
+	cmds, err := transcript.NewProcesses(args[0], n)
 	if err != nil {
 		return nil, err
 	}
 
+	oracles := make([]oracle.Target, n)
+	for i := range oracles {
+		oracles[i] = oracle.NewPowerCommand(cmds[i], 32)
+	}
+
 	return &Attack{
-			cmd:          cmd,
+			oracle:       oracles[0].(oracle.PowerOracle),
+			pool:         pool.NewPool(oracles),
 			conf:         power_c.NewConf(),
 			interactions: 0,
+			samplesN:     traces,
+			traceNum:     window,
+			chunks:       window / CHUNKSIZE,
 		},
 		nil
 }
@@ -98,10 +133,10 @@ func NewAttack() (*Attack, os.Error) {
 func (a *Attack) Run() os.Error {
 	fmt.Printf("Executing Attack.\n")
 
-	if err := a.cmd.Run(); err != nil {
+	if err := a.pool.Run(); err != nil {
 		return err
 	}
-	defer a.cmd.Kill()
+	defer a.pool.Kill()
 
 	now := time.Nanoseconds()
 
@@ -133,9 +168,15 @@ func (a *Attack) Run() os.Error {
 
 		if !correct {
 			fmt.Printf("key was found incorrect.\n")
-			fmt.Printf("Increasing samples and traces.\n")
-			SAMPLES += 10
-			TRACE_NUM += 1000
+			fmt.Printf("Increasing traces and window.\n")
+			a.samplesN += 10
+
+			// The window can grow no longer than the traces
+			a.traceNum += 1000
+			if a.traceNum > a.samples.l {
+				a.traceNum = a.samples.l
+			}
+			a.chunks = a.traceNum / CHUNKSIZE
 
 		} else {
 			found = true
@@ -146,7 +187,15 @@ func (a *Attack) Run() os.Error {
 	fmt.Printf("Elapsed time: %.2fs\n*********\n", float64((time.Nanoseconds()-now))/1e9)
 
 	fmt.Printf("Target material: K1 - K2 [%X - %X]\n", k1, k2)
+	a.report.Set("material", utils.AppendByteSlice(k1, k2))
+	a.report.Set("k1", k1)
+	a.report.Set("k2", k2)
+	a.report.Set("traces", a.samplesN)
+	a.report.Set("window", a.traceNum)
+	a.report.Set("k1_correlations", a.k1Corrs)
+	a.report.Set("k2_correlations", a.k2Corrs)
 	fmt.Printf("Interactions: %d\n", a.interactions)
+	fmt.Printf("Restarts: %d\n", a.pool.Restarts())
 
 	return nil
 }
@@ -154,7 +203,7 @@ func (a *Attack) Run() os.Error {
 func (a *Attack) CheckKey(k1, k2 []byte) (bool, os.Error) {
 	i := big.NewInt(50777216)
 
-	_, _, m, err := a.Interact(i.Bytes())
+	_, _, m, err := a.Interact(a.oracle, i.Bytes())
 	if err != nil {
 		return false, err
 	}
@@ -204,12 +253,14 @@ func (a *Attack) FindKey1(k2 []byte) ([]byte, os.Error) {
 		return nil, err
 	}
 
+	a.k1Corrs = make([]float64, KEY_SIZE)
+
 	for i := 0; i < KEY_SIZE; i++ {
 		a.CalculateKey1Correlations(i, tweaks)
 		max = 0
 
 		for j := 0; j < KEY_RANGE; j++ {
-			for k := 0; k < CHUNKS; k++ {
+			for k := 0; k < a.chunks; k++ {
 				if a.samples.CC[j][k] > max {
 					max = a.samples.CC[j][k]
 					key = byte(j)
@@ -219,6 +270,7 @@ func (a *Attack) FindKey1(k2 []byte) ([]byte, os.Error) {
 		}
 
 		k1 = bytes.AddByte(k1, key)
+		a.k1Corrs[i] = max
 	}
 
 	a.printProgress(k1, max, KEY_SIZE)
@@ -238,13 +290,13 @@ func (a *Attack) CalculateKey1Correlations(b int, tweaks [][]byte) {
 
 	HHT := utils.Transpose(a.samples.HH)
 	a.samples.TT = utils.Transpose(a.samples.traces)
-	a.samples.TT = a.samples.TT[len(a.samples.traces[0])-TRACE_NUM : len(a.samples.traces[0])]
+	a.samples.TT = a.samples.TT[len(a.samples.traces[0])-a.traceNum : len(a.samples.traces[0])]
 
 	a.samples.CC = make([][]float64, KEY_RANGE)
 
 	for i := 0; i < KEY_RANGE; i++ {
-		a.samples.CC[i] = make([]float64, TRACE_NUM)
-		for j := 0; j < CHUNKS; j++ {
+		a.samples.CC[i] = make([]float64, a.traceNum)
+		for j := 0; j < a.chunks; j++ {
 			corr := Correlation(HHT[i], a.samples.TT[j*CHUNKSIZE : (j+1)*CHUNKSIZE][0])
 			a.samples.CC[i][j] = corr
 		}
@@ -252,7 +304,7 @@ func (a *Attack) CalculateKey1Correlations(b int, tweaks [][]byte) {
 }
 
 func (a *Attack) GenerateTweaks(key []byte) ([][]byte, os.Error) {
-	tweaks := make([][]byte, SAMPLES)
+	tweaks := make([][]byte, a.samplesN)
 
 	k, err := aes.NewCipher(key)
 	if err != nil {
@@ -275,12 +327,14 @@ func (a *Attack) FindKey2() []byte {
 
 	a.printProgress(k2, 0, 1)
 
+	a.k2Corrs = make([]float64, KEY_SIZE)
+
 	for i := 0; i < KEY_SIZE; i++ {
 		a.CalculateKey2Correlations(i)
 		max = 0
 
 		for j := 0; j < KEY_RANGE; j++ {
-			for k := 0; k < TRACE_NUM; k++ {
+			for k := 0; k < a.chunks; k++ {
 				if a.samples.CC[j][k] > max {
 					max = a.samples.CC[j][k]
 					key2 = byte(j)
@@ -290,6 +344,7 @@ func (a *Attack) FindKey2() []byte {
 		}
 
 		k2 = bytes.AddByte(k2, key2)
+		a.k2Corrs[i] = max
 	}
 
 	a.printProgress(k2, max, KEY_SIZE)
@@ -308,13 +363,13 @@ func (a *Attack) CalculateKey2Correlations(b int) {
 	}
 
 	HHT := utils.Transpose(a.samples.HH)
-	a.samples.TT = utils.Transpose(a.samples.traces)[0:TRACE_NUM]
+	a.samples.TT = utils.Transpose(a.samples.traces)[0:a.traceNum]
 
 	a.samples.CC = make([][]float64, KEY_RANGE)
 
 	for i := 0; i < KEY_RANGE; i++ {
-		a.samples.CC[i] = make([]float64, TRACE_NUM)
-		for j := 0; j < TRACE_NUM; j++ {
+		a.samples.CC[i] = make([]float64, a.traceNum)
+		for j := 0; j < a.chunks; j++ {
 			corr := Correlation(HHT[i], a.samples.TT[j*CHUNKSIZE : (j+1)*CHUNKSIZE][0])
 			a.samples.CC[i][j] = corr
 		}
@@ -344,23 +399,40 @@ func Correlation(a []float64, b []float64) float64 {
 func (a *Attack) GatherSamples() os.Error {
 
 	samples := &Samples{
-		inputs:  make([][]byte, SAMPLES),
-		traces:  make([][]float64, SAMPLES),
-		outputs: make([][]byte, SAMPLES),
-		HH:      make([][]float64, SAMPLES),
+		inputs:  make([][]byte, a.samplesN),
+		traces:  make([][]float64, a.samplesN),
+		outputs: make([][]byte, a.samplesN),
+		HH:      make([][]float64, a.samplesN),
 	}
 
-	for i := 0; i < SAMPLES; i++ {
-		fmt.Printf("\rGathering Power Samples [%d]...", i)
+	// Sector addresses are chosen up front so they do not depend on the
+	// order the pool answers in
+	js := make([]*big.Int, a.samplesN)
+	for i := range js {
+		js[i] = utils.RandInt(2, 128)
+	}
 
-		samples.HH[i] = make([]float64, KEY_RANGE)
-		j := utils.RandInt(2, 128)
+	ls := make([]int, a.samplesN)
+	ms := make([][]byte, a.samplesN)
 
-		l, ss, m, err := a.Interact(j.Bytes())
+	err := a.pool.Do(a.samplesN, func(o oracle.Target, i int) os.Error {
+		l, ss, m, err := a.Interact(o.(oracle.PowerOracle), js[i].Bytes())
 		if err != nil {
 			return err
 		}
 
+		ls[i], samples.traces[i], ms[i] = l, ss, m
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for i, j := range js {
+		samples.HH[i] = make([]float64, KEY_RANGE)
+		l, ss, m := ls[i], samples.traces[i], ms[i]
+
 		if l != len(ss) {
 			return utils.NewError(fmt.Sprintf("l and length of trace, l=%d len(ss)=%d", l, len(ss)))
 		}
@@ -371,113 +443,38 @@ func (a *Attack) GatherSamples() os.Error {
 		if l != samples.l {
 			return utils.NewError(fmt.Sprintf("in consistent l, l=%d alen=%d", l, samples.l))
 		}
+		if a.traceNum > l {
+			return utils.NewError(fmt.Sprintf("window longer than the traces, window=%d l=%d", a.traceNum, l))
+		}
 
 		oct := make([]byte, len(m)/2)
 		samples.inputs[i] = make([]byte, MESSAGE_SIZE)
 		hex.Decode(oct, m)
 
 		samples.outputs[i] = oct
-		samples.traces[i] = ss
 		copy(samples.inputs[i][MESSAGE_SIZE-len(j.Bytes()):], j.Bytes())
 	}
 
-	fmt.Printf("\rGathering Power Samples [%d]...", SAMPLES)
+	fmt.Printf("\rGathering Power Samples [%d]...", a.samplesN)
 
 	a.samples = samples
 
 	return nil
 }
 
-func (a *Attack) Interact(i []byte) (l int, ss []float64, m []byte, err os.Error) {
-	if err := a.Write(i); err != nil {
-		return -1, nil, nil, err
-	}
-
-	l, ss, m, err = a.Read()
+func (a *Attack) Interact(o oracle.PowerOracle, i []byte) (l int, ss []float64, m []byte, err os.Error) {
+	l, ss, m, err = o.Trace(0, i)
 	if err != nil {
 		return -1, nil, nil, err
 	}
 
+	a.mu.Lock()
 	a.interactions++
+	a.mu.Unlock()
 
 	return l, ss, m, nil
 }
 
-func (a *Attack) Write(sectorAddr []byte) os.Error {
-	i := make([]byte, len(sectorAddr)*2)
-	hex.Encode(i, sectorAddr)
-	i = utils.Pad(bytes.AddByte(i, '\n'), 32)
-	j := []byte{'0', '\n'}
-
-	if err := a.cmd.WriteStdin(j); err != nil {
-		return utils.Error("failed to write block adress", err)
-	}
-
-	if err := a.cmd.WriteStdin(i); err != nil {
-		return utils.Error("failed to write sector address", err)
-	}
-
-	return nil
-}
-
-func (a *Attack) Read() (l int, ss []float64, m []byte, err os.Error) {
-	p, err := a.cmd.ReadStdout()
-	if err != nil {
-		return -1, nil, nil, utils.Error("failed to read power consumption", err)
-	}
-
-	str := strings.Split(fmt.Sprintf("%s", p), ",", 0)
-	l, err = strconv.Atoi(str[0])
-	if err != nil {
-		return -1, nil, nil, utils.Error("failed to convert power length integer string", err)
-	}
-
-	start := 0
-	for i, b := range p {
-		if b == ',' {
-			start = i + 1
-			break
-		}
-	}
-
-	var tmp float64
-	for _, b := range p[start:] {
-		if b != ',' {
-			tmp = 10*tmp + (float64(b) - 48)
-		} else {
-			ss = utils.AppendFloat(ss, tmp)
-			tmp = 0
-		}
-	}
-
-	for {
-		p, err := a.cmd.ReadStdout()
-		if err != nil {
-			return -1, nil, nil, utils.Error("failed to read power consumption", err)
-		}
-		for i, b := range p {
-			if b == '\n' {
-				if tmp != 0 {
-					ss = utils.AppendFloat(ss, tmp)
-				}
-
-				m = bytes.Split(p[i+1:], []byte{'\n'}, 0)[0]
-
-				return l, ss, m, nil
-			}
-
-			if b != ',' {
-				tmp = 10*tmp + (float64(b) - 48)
-			} else {
-				ss = utils.AppendFloat(ss, tmp)
-				tmp = 0
-			}
-		}
-	}
-
-	return
-}
-
 func (a *Attack) printProgress(key []byte, corr float64, i int) {
 	str := ""
 	for _, k := range key {
@@ -0,0 +1,70 @@
+///////////////////////////////////////////////////////////
+//                                                       //
+//                 Joshua Van Leeuwen                    //
+//                                                       //
+//                University of Bristol                  //
+//                                                       //
+///////////////////////////////////////////////////////////
+
+package main
+
+import (
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"os"
+
+	"./power_s"
+	"./utils"
+)
+
+var (
+	k1      = flag.String("k1", "", "hex AES-128 block key (default random)")
+	k2      = flag.String("k2", "", "hex AES-128 tweak key (default random)")
+	samples = flag.Int("samples", 20000, "number of samples in each trace")
+	noise   = flag.Float64("noise", 2, "standard deviation of the sample noise")
+	jitter  = flag.Int("jitter", 0, "maximum misalignment in samples between traces")
+	seed    = flag.Int64("seed", 0, "seed of the noise and jitter (default time based)")
+)
+
+func main() {
+	flag.Parse()
+
+	s, err := newSimulator()
+	if err != nil {
+		utils.Fatal(err)
+	}
+
+	s.Length = *samples
+	s.Noise = *noise
+	s.Jitter = *jitter
+	if *seed != 0 {
+		s.Seed(*seed)
+	}
+
+	key1, key2 := s.Keys()
+	fmt.Fprintf(os.Stderr, "Keys: K1 - K2 [%X - %X]\n", key1, key2)
+
+	if err := s.Serve(os.Stdin, os.Stdout); err != nil {
+		utils.Fatal(err)
+	}
+}
+
+// Initialise the simulator with the key flags if both are given
+func newSimulator() (*power_s.Simulator, os.Error) {
+	if *k1 == "" || *k2 == "" {
+		return power_s.NewSimulator()
+	}
+
+	key1 := make([]byte, len(*k1)/2)
+	if _, err := hex.Decode(key1, []byte(*k1)); err != nil {
+		return nil, utils.Error("failed to decode k1", err)
+	}
+
+	key2 := make([]byte, len(*k2)/2)
+	if _, err := hex.Decode(key2, []byte(*k2)); err != nil {
+		return nil, utils.Error("failed to decode k2", err)
+	}
+
+	return power_s.NewSimulatorKey(key1, key2)
+}
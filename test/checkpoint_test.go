@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"./time_a"
+)
+
+const (
+	CONF = "checkpoint_test.conf"
+	FILE = "checkpoint_test.txt"
+	COPY = "checkpoint_test_copy.txt"
+
+	CHECKPOINT = "# time attack checkpoint, one 'x t time' line per sample\n" +
+		"N = C5\n" +
+		"set = 2\n" +
+		"interactions = 3\n" +
+		"bits = 101\n" +
+		"diffs = 0.125 0.5\n" +
+		"1F 2A 1234.5\n" +
+		"03 C4 987\n"
+)
+
+func main() {
+	defer os.Remove(CONF)
+	defer os.Remove(FILE)
+	defer os.Remove(COPY)
+
+	write(CONF, "N = C5\ne = 03\n")
+
+	// Samples and bits resumed are saved again unchanged
+	write(FILE, CHECKPOINT)
+	a := attack()
+	expError("", a.LoadCheckpoint())
+
+	a.SetCheckpoint(COPY)
+	expError("", a.SaveCheckpoint())
+
+	b, err := ioutil.ReadFile(COPY)
+	if err != nil {
+		panic(err)
+	}
+	expBool(true, bytes.Equal([]byte(CHECKPOINT), b))
+
+	// Errors name the checkpoint and line
+	write(FILE, strings.Replace(CHECKPOINT, "03 C4 987", "03 C4", 1))
+	expError(fmt.Sprintf("checkpoint '%s' line 8", FILE), attack().LoadCheckpoint())
+
+	write(FILE, strings.Replace(CHECKPOINT, "N = C5", "N = C7", 1))
+	expError("different N", attack().LoadCheckpoint())
+
+	write(FILE, strings.Replace(CHECKPOINT, "bits = 101", "bits = 121", 1))
+	expError("expected binary bits", attack().LoadCheckpoint())
+}
+
+func attack() *time_a.Attack {
+	a, err := time_a.NewAttack([]string{"/bin/cat", CONF}, 1, 0.01, 1)
+	if err != nil {
+		panic(err)
+	}
+	a.SetCheckpoint(FILE)
+
+	return a
+}
+
+func write(fileName, s string) {
+	if err := ioutil.WriteFile(fileName, []byte(s), 0644); err != nil {
+		panic(err)
+	}
+}
+
+func expError(exp string, err os.Error) {
+	if exp == "" && err == nil {
+		fmt.Printf("PASSED.\n")
+		return
+	}
+
+	if exp == "" || err == nil || !strings.Contains(err.String(), exp) {
+		fmt.Printf("FAILED. exp=%s got=%v\n", exp, err)
+		return
+	}
+
+	fmt.Printf("PASSED.\n")
+}
+
+func expBool(exp, got bool) {
+	if exp != got {
+		fmt.Printf("FAILED. exp=%v got=%v\n", exp, got)
+		return
+	}
+
+	fmt.Printf("PASSED.\n")
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"time"
+
+	"./command"
+)
+
+const (
+	MARK = "command_test.mark"
+)
+
+func main() {
+	defer os.Remove(MARK)
+
+	// cat answers each request with exactly its own lines
+	c, err := command.NewCommand("/bin/cat")
+	if err != nil {
+		panic(err)
+	}
+
+	if err := c.Run(); err != nil {
+		panic(err)
+	}
+
+	for i := 0; i < 3; i++ {
+		exp := []byte(fmt.Sprintf("%d,%d,%d", i, i+1, i+2))
+		if err := c.WriteStdin([]byte(fmt.Sprintf("%s\nAB%d\n", exp, i))); err != nil {
+			panic(err)
+		}
+
+		lines, err := c.ReadLines(2)
+		if err != nil {
+			panic(err)
+		}
+
+		expBool(true, bytes.Equal(exp, lines[0]))
+		expBool(true, bytes.Equal([]byte(fmt.Sprintf("AB%d", i)), lines[1]))
+	}
+
+	if err := c.WriteStdin([]byte("a;b;c\n")); err != nil {
+		panic(err)
+	}
+
+	b, err := c.ReadUntil(';')
+	if err != nil {
+		panic(err)
+	}
+	expBool(true, bytes.Equal([]byte("a;"), b))
+
+	b, err = c.ReadLine()
+	if err != nil {
+		panic(err)
+	}
+	expBool(true, bytes.Equal([]byte("b;c"), b))
+
+	// Nothing more was written so the read times out
+	c.MaxRestarts = 0
+	c.Timeout = 1e8
+	_, err = c.ReadLine()
+	expBool(true, err != nil)
+
+	// and the command must be run again before reading
+	c.Timeout = 0
+	_, err = c.ReadLine()
+	expBool(true, err != nil)
+
+	if err := c.Kill(); err != nil {
+		panic(err)
+	}
+
+	// sh answers two lines then exits, so it is restarted and warmed up
+	// after every second request
+	c, err = command.NewCommandArgs("/bin/sh", []string{"-c", "read a; echo $a; read b; echo $b"})
+	if err != nil {
+		panic(err)
+	}
+	c.SetWarmUp([]byte("warm\n"), 1)
+
+	if err := c.Run(); err != nil {
+		panic(err)
+	}
+
+	for i := 0; i < 6; i++ {
+		exp := []byte(fmt.Sprintf("%d", i))
+		if err := c.WriteStdin(bytes.AddByte(exp, '\n')); err != nil {
+			panic(err)
+		}
+
+		b, err := c.ReadLine()
+		if err != nil {
+			panic(err)
+		}
+
+		expBool(true, bytes.Equal(exp, b))
+	}
+
+	// MaxRestarts limits each interaction, not the life of the command
+	expInt(4, c.Restarts())
+
+	// Delimited reads are restarted too
+	if err := c.WriteStdin([]byte("x;y\n")); err != nil {
+		panic(err)
+	}
+
+	b, err = c.ReadUntil(';')
+	if err != nil {
+		panic(err)
+	}
+	expBool(true, bytes.Equal([]byte("x;"), b))
+	expInt(5, c.Restarts())
+
+	// true never answers, so the interaction fails once its restarts run out
+	c, err = command.NewCommand("/bin/true")
+	if err != nil {
+		panic(err)
+	}
+
+	if err := c.Run(); err != nil {
+		panic(err)
+	}
+
+	err = c.WriteStdin([]byte("5\n"))
+	if err == nil {
+		_, err = c.ReadLine()
+	}
+	expBool(true, err != nil)
+	expInt(command.DEFAULT_RESTARTS, c.Restarts())
+
+	// The first run answers only after the timeout, from a job that
+	// outlives it. The read left waiting on it must not go on to take the
+	// answers of the command run in its place.
+	c, err = command.NewCommandArgs("/bin/sh", []string{"-c", "if [ -e " + MARK + " ]; then while read a; do echo $a; echo $a; done; else touch " + MARK + "; { sleep 0.4; echo stale; sleep 2; } & wait; fi"})
+	if err != nil {
+		panic(err)
+	}
+	c.Timeout = 2e8
+
+	if err := c.Run(); err != nil {
+		panic(err)
+	}
+
+	for _, exp := range []string{"late", "next"} {
+		if err := c.WriteStdin([]byte(exp + "\n")); err != nil {
+			panic(err)
+		}
+
+		lines, err := c.ReadLines(2)
+		if err != nil {
+			panic(err)
+		}
+		expBool(true, string(lines[0]) == exp && string(lines[1]) == exp)
+
+		// Past the stale answer
+		time.Sleep(6e8)
+	}
+	expInt(1, c.Restarts())
+}
+
+func expBool(exp, got bool) {
+	if exp != got {
+		fmt.Printf("FAILED. exp=%v got=%v\n", exp, got)
+		return
+	}
+
+	fmt.Printf("PASSED.\n")
+}
+
+func expInt(exp, got int) {
+	if exp != got {
+		fmt.Printf("FAILED. exp=%d got=%d\n", exp, got)
+		return
+	}
+
+	fmt.Printf("PASSED.\n")
+}
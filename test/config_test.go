@@ -0,0 +1,174 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"syscall"
+
+	"./oaep_c"
+	"./time_c"
+)
+
+const (
+	FILE = "config_test.conf"
+
+	// Pads N out to 68 octets, enough for OAEP with SHA-256
+	Z = "000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000"
+)
+
+func main() {
+	defer os.Remove(FILE)
+
+	// Keyed fields in any order, with comments and upper case names
+	conf := oaepConf("# keyed\n\nciphertext = 0A\n; label\nlabel = FD93\nN = 0BB5"+Z+"\ne = 03\n")
+	expString("BB5"+Z, fmt.Sprintf("%X", conf.N))
+	expString("3", fmt.Sprintf("%X", conf.E))
+	expString("A", fmt.Sprintf("%X", conf.C))
+	expString("FD93\n", string(conf.L))
+	expString("68", conf.K.String())
+	expString(oaep_c.SHA1, conf.Hash)
+	expString(oaep_c.SHA1, conf.MGF)
+
+	// Legacy one value per line, without a final new line
+	conf = oaepConf("0BB5"+Z+"\n03\nFD93\n0A")
+	expString("BB5"+Z, fmt.Sprintf("%X", conf.N))
+	expString("A", fmt.Sprintf("%X", conf.C))
+
+	// Key length from the bits of N, not its hex digits
+	conf = oaepConf("N = 00000BB5"+Z+"\ne = 03\nlabel = \nciphertext = 0A\n")
+	expString("68", conf.K.String())
+	expString("100"+Z, fmt.Sprintf("%X", conf.B))
+
+	// Empty legacy label
+	conf = oaepConf("0BB5"+Z+"\n03\n\n0A\n")
+	expString("\n", string(conf.L))
+
+	// MGF1 hash follows the label hash unless set
+	conf = oaepConf("N = 0BB5"+Z+"\ne = 03\nlabel = \nciphertext = 0A\nhash = SHA224\n")
+	expString(oaep_c.SHA224, conf.MGF)
+	conf = oaepConf("N = 0BB5"+Z+"\ne = 03\nlabel = \nciphertext = 0A\nhash = sha224\nmgf = sha1\n")
+	expString(oaep_c.SHA1, conf.MGF)
+
+	// Overriding the hash alone moves MGF1 with it
+	expBool(true, conf.SetHash(oaep_c.SHA256, "") == nil)
+	expString(oaep_c.SHA256, conf.MGF)
+	expBool(true, conf.SetHash("", oaep_c.SHA1) == nil)
+	expString(oaep_c.SHA256, conf.Hash)
+	expString(oaep_c.SHA1, conf.MGF)
+
+	// N too short for the hash
+	expError("sha512 needs N of at least 130 octets", conf.SetHash(oaep_c.SHA512, ""))
+	expError("unknown hash 'md5'", conf.SetHash("", "md5"))
+
+	// Further ciphertexts numbered from 2, in any order
+	conf = oaepConf("N = 0BB5"+Z+"\ne = 03\nlabel = \nciphertext = 0A\nciphertext3 = 0C\nlabel2 = FD93\nciphertext2 = 0B\nlabel3 = \n")
+	expString("3", fmt.Sprintf("%d", len(conf.Ciphertexts)))
+	expString("A", fmt.Sprintf("%X", conf.C))
+	expString("B", fmt.Sprintf("%X", conf.Ciphertexts[1].C))
+	expString("FD93\n", string(conf.Ciphertexts[1].L))
+	expString("C", fmt.Sprintf("%X", conf.Select(2).C))
+	expString("\n", string(conf.Select(2).L))
+	expString("A", fmt.Sprintf("%X", conf.C))
+
+	// Decimal integers
+	tconf := timeConf("encoding = decimal\nN = 2997\ne = 3\n")
+	expString("BB5", fmt.Sprintf("%X", tconf.N))
+
+	// Errors name the field and its line
+	expError("line 3: field 'e'", oaepError("N = 0BB5"+Z+"\n\ne = 0BB6"+Z+"\nlabel = \nciphertext = 0A\n"))
+	expError("field 'ciphertext'", oaepError("N = 0BB5"+Z+"\ne = 03\nlabel = \nciphertext = 0BB6"+Z+"\n"))
+	expError("line 2: field 'n'", timeError("e = 03\nN = XYZ\n"))
+	expError("line 2: field 'label'", oaepError("N = 0BB5"+Z+"\nlabel = F\ne = 03\nciphertext = 0A\n"))
+	expError("missing field 'ciphertext'", oaepError("N = 0BB5"+Z+"\ne = 03\nlabel = \n"))
+	expError("unknown field 'd'", timeError("N = 0BB5"+Z+"\nd = 03\n"))
+	expError("already set on line 1", timeError("N = 0BB5"+Z+"\nN = 0BB5"+Z+"\n"))
+	expError("missing field 'label2'", oaepError("N = 0BB5"+Z+"\ne = 03\nlabel = \nciphertext = 0A\nciphertext2 = 0B\n"))
+	expError("missing field 'ciphertext2'", oaepError("N = 0BB5"+Z+"\ne = 03\nlabel = \nciphertext = 0A\nlabel3 = \nciphertext3 = 0B\n"))
+	expError("unknown field 'ciphertext1'", oaepError("N = 0BB5"+Z+"\ne = 03\nlabel = \nciphertext = 0A\nciphertext1 = 0B\n"))
+	expError("field 'ciphertext2'", oaepError("N = 0BB5"+Z+"\ne = 03\nlabel = \nciphertext = 0A\nlabel2 = \nciphertext2 = 0BB6"+Z+"\n"))
+	expError("line 1: field 'n': expected 2B < N", oaepError("N = 01"+Z+"\ne = 03\nlabel = \nciphertext = 0A\n"))
+	expError("field 'hash'", oaepError("N = 0BB5"+Z+"\ne = 03\nlabel = \nciphertext = 0A\nhash = md5\n"))
+	expError("line 1: field 'n': expected an unsigned hex integer", timeError("N = -0BB5"+Z+"\ne = 03\n"))
+	expError("line 2: field 'e': expected an unsigned decimal integer", timeError("encoding = decimal\ne = +3\nN = 2997\n"))
+	expError("line 4: field 'ciphertext'", oaepError("N = 0BB5"+Z+"\ne = 03\nlabel = \nciphertext = -0A\n"))
+}
+
+func oaepConf(s string) *oaep_c.Conf {
+	write(s)
+
+	conf, err := oaep_c.NewConf(FILE)
+	if err != nil {
+		panic(err)
+	}
+
+	return conf
+}
+
+func timeConf(s string) *time_c.Conf {
+	write(s)
+
+	conf, err := time_c.NewConf(FILE)
+	if err != nil {
+		panic(err)
+	}
+
+	return conf
+}
+
+func oaepError(s string) os.Error {
+	write(s)
+
+	_, err := oaep_c.NewConf(FILE)
+	return err
+}
+
+func timeError(s string) os.Error {
+	write(s)
+
+	_, err := time_c.NewConf(FILE)
+	return err
+}
+
+// Write s as is, so the last line may lack a new line
+func write(s string) {
+	f, err := os.Open(FILE, syscall.O_WRONLY|syscall.O_CREAT|syscall.O_TRUNC, 0644)
+	if err != nil {
+		panic(err)
+	}
+
+	if _, err := f.WriteString(s); err != nil {
+		panic(err)
+	}
+
+	if err := f.Close(); err != nil {
+		panic(err)
+	}
+}
+
+func expString(exp, got string) {
+	if exp != got {
+		fmt.Printf("FAILED. exp=%q got=%q\n", exp, got)
+		return
+	}
+
+	fmt.Printf("PASSED.\n")
+}
+
+func expBool(exp, got bool) {
+	if exp != got {
+		fmt.Printf("FAILED. exp=%v got=%v\n", exp, got)
+		return
+	}
+
+	fmt.Printf("PASSED.\n")
+}
+
+func expError(exp string, err os.Error) {
+	if err == nil || strings.Index(err.String(), exp) < 0 {
+		fmt.Printf("FAILED. exp=%q got=%v\n", exp, err)
+		return
+	}
+
+	fmt.Printf("PASSED.\n")
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"big"
+	"bytes"
+	"fmt"
+	"os"
+
+	"./crt_c"
+	"./crt_s"
+)
+
+var (
+	s    *crt_s.Simulator
+	conf *crt_c.Conf
+)
+
+func main() {
+	var err os.Error
+
+	s, err = crt_s.NewSimulator(512)
+	if err != nil {
+		panic(err)
+	}
+	s.Seed(1)
+
+	conf = &crt_c.Conf{N: s.N, E: s.E}
+	m := big.NewInt(0xB6562D3E)
+
+	// No fault is a correct signature
+	sig := sign([]byte{'\n'}, m)
+	expString(m.String(), new(big.Int).Exp(sig, s.E, s.N).String())
+
+	// Either half faulted gives the smaller prime
+	for _, fault := range []string{"p\n", "q\n"} {
+		f := sign([]byte(fault), m)
+
+		p, _, err := conf.FactorPair(sig, f)
+		expFactor(p, err)
+
+		p, _, err = conf.FactorMessage(m, f)
+		expFactor(p, err)
+	}
+
+	// A signature without a fault is no use
+	_, _, err = conf.FactorPair(sig, sig)
+	expBool(true, err != nil)
+
+	_, err = s.Sign([]byte("r\n"), m)
+	expBool(true, err != nil)
+
+	// Signatures over a stream
+	var w bytes.Buffer
+	r := bytes.NewBufferString(fmt.Sprintf("\n%X\n", m.Bytes()))
+	if err := s.Serve(r, &w); err != nil {
+		panic(err)
+	}
+	expString(fmt.Sprintf("%X\n", sig.Bytes()), w.String())
+}
+
+func sign(fault []byte, m *big.Int) *big.Int {
+	sig, err := s.Sign(fault, m)
+	if err != nil {
+		panic(err)
+	}
+
+	return sig
+}
+
+func expFactor(p *big.Int, err os.Error) {
+	if err != nil {
+		fmt.Printf("FAILED. %s\n", err.String())
+		return
+	}
+
+	expString(s.P().String(), p.String())
+}
+
+func expString(exp, got string) {
+	if exp != got {
+		fmt.Printf("FAILED. exp=%s got=%s\n", exp, got)
+		return
+	}
+
+	fmt.Printf("PASSED.\n")
+}
+
+func expBool(exp, got bool) {
+	if exp != got {
+		fmt.Printf("FAILED. exp=%v got=%v\n", exp, got)
+		return
+	}
+
+	fmt.Printf("PASSED.\n")
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bytes"
+	"crypto/aes"
+	"fmt"
+	"os"
+
+	"./fault_s"
+)
+
+var (
+	s *fault_s.Simulator
+)
+
+func main() {
+	var err os.Error
+
+	s, err = fault_s.NewSimulator()
+	if err != nil {
+		panic(err)
+	}
+	s.Seed(1)
+
+	m := []byte{0x32, 0x43, 0xF6, 0xA8, 0x88, 0x5A, 0x30, 0x8D, 0x31, 0x31, 0x98, 0xA2, 0xE0, 0x37, 0x07, 0x34}
+
+	// No fault is plain AES-128
+	c, err := s.Encrypt([]byte{'\n'}, m)
+	if err != nil {
+		panic(err)
+	}
+
+	k, err := aes.NewCipher(s.Key())
+	if err != nil {
+		panic(err)
+	}
+	exp := make([]byte, len(m))
+	k.Encrypt(m, exp)
+	expBool(true, bytes.Equal(exp, c))
+
+	// A round 8 fault spreads to every byte, a round 9 fault to one column
+	fault_test(c, m, []byte("8,1,0,0,0\n"), 16)
+	fault_test(c, m, []byte("9,1,0,0,0\n"), 4)
+	fault_test(c, m, []byte("10,1,0,0,0\n"), 1)
+
+	_, err = s.Encrypt([]byte("11,1,0,0,0\n"), m)
+	expBool(true, err != nil)
+}
+
+func fault_test(c, m, fault []byte, exp int) {
+	c2, err := s.Encrypt(fault, m)
+	if err != nil {
+		panic(err)
+	}
+
+	diff := 0
+	for i := range c {
+		if c[i] != c2[i] {
+			diff++
+		}
+	}
+
+	expInt(exp, diff)
+}
+
+func expBool(exp, got bool) {
+	if exp != got {
+		fmt.Printf("FAILED. exp=%v got=%v\n", exp, got)
+		return
+	}
+
+	fmt.Printf("PASSED.\n")
+}
+
+func expInt(exp, got int) {
+	if exp != got {
+		fmt.Printf("FAILED. exp=%d got=%d\n", exp, got)
+		return
+	}
+
+	fmt.Printf("PASSED.\n")
+}
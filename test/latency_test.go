@@ -0,0 +1,88 @@
+package main
+
+import (
+	"big"
+	"fmt"
+	"os"
+	"time"
+
+	"./oracle"
+	"./utils"
+)
+
+const SLOW = 10
+
+// Target answering ERROR2 to everything, slower from SLOW up
+type target struct{}
+
+func (t *target) Run() os.Error  { return nil }
+func (t *target) Kill() os.Error { return nil }
+func (t *target) Restarts() int  { return 0 }
+
+func (t *target) Decrypt(l []byte, c *big.Int) (byte, os.Error) {
+	if c.Cmp(big.NewInt(SLOW)) >= 0 {
+		time.Sleep(2e6)
+	}
+
+	return oracle.ERROR2, nil
+}
+
+func main() {
+	expFloat(2, utils.MedianFloat([]float64{3, 1, 2}))
+	expFloat(2.5, utils.MedianFloat([]float64{4, 1, 3, 2}))
+
+	o := oracle.NewLatencyOracle(&target{}, 3)
+
+	fast := []*big.Int{big.NewInt(1), big.NewInt(2), big.NewInt(3)}
+	slow := []*big.Int{big.NewInt(11), big.NewInt(12), big.NewInt(13)}
+
+	t1, t2, accuracy, err := o.Calibrate(nil, fast, slow)
+	if err != nil {
+		panic(err)
+	}
+	expBool(true, t1 < t2)
+	expFloat(1, accuracy)
+
+	code, _ := o.Decrypt(nil, big.NewInt(4))
+	expByte(oracle.ERROR1, code)
+
+	code, _ = o.Decrypt(nil, big.NewInt(14))
+	expByte(oracle.ERROR2, code)
+
+	// Either class may be the slow one
+	t1, t2, _, _ = o.Calibrate(nil, slow, fast)
+	expBool(true, t1 > t2)
+
+	code, _ = o.Decrypt(nil, big.NewInt(14))
+	expByte(oracle.ERROR1, code)
+
+	_, _, _, err = o.Calibrate(nil, fast, nil)
+	expBool(true, err != nil)
+}
+
+func expBool(exp, got bool) {
+	if exp != got {
+		fmt.Printf("FAILED. exp=%v got=%v\n", exp, got)
+		return
+	}
+
+	fmt.Printf("PASSED.\n")
+}
+
+func expFloat(exp, got float64) {
+	if exp != got {
+		fmt.Printf("FAILED. exp=%v got=%v\n", exp, got)
+		return
+	}
+
+	fmt.Printf("PASSED.\n")
+}
+
+func expByte(exp, got byte) {
+	if exp != got {
+		fmt.Printf("FAILED. exp=%c got=%c\n", exp, got)
+		return
+	}
+
+	fmt.Printf("PASSED.\n")
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"big"
+	"fmt"
+	"os"
+	"strconv"
+
+	"./oaep_a"
+	"./oaep_c"
+	"./oaep_s"
+	"./oracle"
+	"./report"
+)
+
+const (
+	CONF = "oaep_optimise_test.conf"
+	KEY  = "oaep_optimise_test.key"
+)
+
+// Simulator counting the queries made of it
+type Counter struct {
+	*oaep_s.Simulator
+	n int
+}
+
+func (c *Counter) Decrypt(l []byte, ct *big.Int) (byte, os.Error) {
+	c.n++
+	return c.Simulator.Decrypt(l, ct)
+}
+
+func main() {
+	defer os.Remove(CONF)
+	defer os.Remove(KEY)
+
+	s, err := oaep_s.NewSimulator(1024)
+	if err != nil {
+		panic(err)
+	}
+
+	if err := s.WriteKey(KEY); err != nil {
+		panic(err)
+	}
+
+	m := []byte("attack at dawn")
+	l := []byte{0xFD, 0x93, 0xC0, 0x20}
+
+	c, err := s.Challenge(m, l)
+	if err != nil {
+		panic(err)
+	}
+
+	if err := s.WriteConf(CONF, [][]byte{l}, []*big.Int{c}); err != nil {
+		panic(err)
+	}
+
+	material := fmt.Sprintf("%q", fmt.Sprintf("%X", m))
+
+	// Manger as published
+	r, plain := run(1, false)
+	expString(material, r.Get("material"))
+
+	// Optimised, counting the queries of the unoptimised attack offline
+	r, optimised := run(1, true)
+	expString(material, r.Get("material"))
+	expString(strconv.Itoa(plain), r.Get("baseline_interactions"))
+	expBool(true, optimised < plain)
+
+	// F2 windows queried over a pool only add speculative queries
+	r, pooled := run(4, true)
+	expString(material, r.Get("material"))
+	expString(strconv.Itoa(plain), r.Get("baseline_interactions"))
+	expBool(true, pooled >= optimised)
+}
+
+// Run the attack over n copies of the simulator, returning the report and
+// the queries made
+func run(n int, optimise bool) (*report.Report, int) {
+	conf, err := oaep_c.NewConf(CONF)
+	if err != nil {
+		panic(err)
+	}
+
+	counters := make([]*Counter, n)
+	oracles := make([]oracle.DecryptOracle, n)
+	for i := range oracles {
+		s, err := oaep_s.NewSimulatorFile(KEY)
+		if err != nil {
+			panic(err)
+		}
+
+		counters[i] = &Counter{Simulator: s}
+		oracles[i] = counters[i]
+	}
+
+	a := oaep_a.NewAttackOracles(conf, oracles)
+	a.SetOptimise(optimise)
+
+	r := report.NewReport("oaep")
+	a.SetReport(r)
+
+	// Progress goes to stderr so stdout holds only the results
+	out := os.Stdout
+	os.Stdout = os.Stderr
+	err = a.Run()
+	os.Stdout = out
+
+	if err != nil {
+		panic(err)
+	}
+
+	queries := 0
+	for _, c := range counters {
+		queries += c.n
+	}
+
+	return r, queries
+}
+
+func expString(exp, got string) {
+	if exp != got {
+		fmt.Printf("FAILED. exp=%s got=%s\n", exp, got)
+		return
+	}
+
+	fmt.Printf("PASSED.\n")
+}
+
+func expBool(exp, got bool) {
+	if exp != got {
+		fmt.Printf("FAILED. exp=%v got=%v\n", exp, got)
+		return
+	}
+
+	fmt.Printf("PASSED.\n")
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"big"
+	"fmt"
+	"os"
+
+	"./oaep_s"
+)
+
+var (
+	s *oaep_s.Simulator
+	l = []byte("FD93C020BD3ABC96178198FAE2D320BB\n")
+)
+
+func main() {
+	var err os.Error
+
+	s, err = oaep_s.NewSimulator(1024)
+	if err != nil {
+		panic(err)
+	}
+
+	c, err := s.Challenge([]byte{0xB6, 0x56, 0x2D, 0x3E}, []byte{0xFD, 0x93, 0xC0, 0x20, 0xBD, 0x3A, 0xBC, 0x96, 0x17, 0x81, 0x98, 0xFA, 0xE2, 0xD3, 0x20, 0xBB})
+	if err != nil {
+		panic(err)
+	}
+
+	// Valid ciphertext and label
+	decrypt_test(c, oaep_s.SUCCESS)
+
+	// Wrong label
+	decrypt_test_label([]byte("00\n"), c, oaep_s.ERROR2)
+
+	// 1^e = 1 < B, but not a valid encoding
+	decrypt_test(big.NewInt(1), oaep_s.ERROR2)
+
+	// Raising N-1 keeps the leading byte non zero
+	decrypt_test(new(big.Int).Sub(s.N(), big.NewInt(1)), oaep_s.ERROR1)
+
+	// Ciphertext out of range
+	decrypt_test(s.N(), oaep_s.ERROR_INPUT)
+
+	// Full noise swaps ERROR1 and ERROR2 but leaves the other codes
+	s.Noise = 1
+	decrypt_test(big.NewInt(1), oaep_s.ERROR1)
+	decrypt_test(new(big.Int).Sub(s.N(), big.NewInt(1)), oaep_s.ERROR2)
+	decrypt_test(c, oaep_s.SUCCESS)
+	decrypt_test(s.N(), oaep_s.ERROR_INPUT)
+}
+
+func decrypt_test(c *big.Int, exp byte) {
+	decrypt_test_label(l, c, exp)
+}
+
+func decrypt_test_label(l []byte, c *big.Int, exp byte) {
+	code, err := s.Decrypt(l, c)
+	if err != nil {
+		panic(err)
+	}
+	expByte(exp, code)
+}
+
+func expByte(exp, got byte) {
+	if exp != got {
+		fmt.Printf("FAILED. exp=%c got=%c\n", exp, got)
+		return
+	}
+
+	fmt.Printf("PASSED.\n")
+}
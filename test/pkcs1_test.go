@@ -0,0 +1,153 @@
+package main
+
+import (
+	"big"
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"fmt"
+
+	"./pkcs1"
+)
+
+func main() {
+	// I2OSP pads to length and rejects integers that do not fit
+	X, err := pkcs1.I2OSP(big.NewInt(258), 4)
+	expBool(true, err == nil)
+	expBytes([]byte{0, 0, 1, 2}, X)
+	expBool(true, pkcs1.OS2IP(X).Cmp(big.NewInt(258)) == 0)
+
+	_, err = pkcs1.I2OSP(big.NewInt(256), 1)
+	expBool(true, err != nil)
+
+	// MGF1 is a prefix of longer masks
+	m1, _ := pkcs1.MGF1(sha1.New, []byte{1, 2, 3}, 10)
+	m2, _ := pkcs1.MGF1(sha1.New, []byte{1, 2, 3}, 50)
+	expBytes(m1, m2[0:10])
+
+	priv, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		panic(err)
+	}
+
+	N := priv.N
+	e := big.NewInt(int64(priv.E))
+	d := priv.D
+	k := len(N.Bytes())
+
+	p := &pkcs1.Params{Hash: sha256.New, MGF: sha1.New, Label: []byte("label")}
+	M := []byte("attack at dawn")
+
+	// Round trip
+	C, err := pkcs1.Encrypt(rand.Reader, N, e, p, M)
+	expBool(true, err == nil)
+	expInt(k, len(C))
+
+	got, err := pkcs1.Decrypt(N, d, p, C)
+	expBool(true, err == nil)
+	expBytes(M, got)
+
+	// Encryption is randomised by the seed
+	C2, _ := pkcs1.Encrypt(rand.Reader, N, e, p, M)
+	expBool(false, bytes.Equal(C, C2))
+
+	// A fixed seed encodes deterministically, with a leading zero
+	seed := make([]byte, 32)
+	EM1, _ := pkcs1.EncodeSeed(p, k, M, seed)
+	EM2, _ := pkcs1.EncodeSeed(p, k, M, seed)
+	expBytes(EM1, EM2)
+	expInt(0, int(EM1[0]))
+
+	// Wrong label or MGF1 hash fail alike
+	_, err1 := pkcs1.Decrypt(N, d, &pkcs1.Params{Hash: sha256.New, MGF: sha1.New, Label: []byte("other")}, C)
+	_, err2 := pkcs1.Decrypt(N, d, &pkcs1.Params{Hash: sha256.New, MGF: sha256.New, Label: []byte("label")}, C)
+	expBool(true, err1 != nil && err2 != nil && err1.String() == err2.String())
+
+	// Empty message and the longest message fit
+	for _, l := range []int{0, k - 2*32 - 2} {
+		M = make([]byte, l)
+		C, err = pkcs1.Encrypt(rand.Reader, N, e, p, M)
+		expBool(true, err == nil)
+		got, err = pkcs1.Decrypt(N, d, p, C)
+		expBool(true, err == nil)
+		expBytes(M, got)
+	}
+
+	_, err = pkcs1.Encrypt(rand.Reader, N, e, p, make([]byte, k-2*32-1))
+	expBool(true, err != nil)
+
+	// PKCS#1 v1.5 round trip, up to the longest message
+	for _, l := range []int{0, 14, k - 11} {
+		M = make([]byte, l)
+		C, err = pkcs1.EncryptV15(rand.Reader, N, e, M)
+		expBool(true, err == nil)
+		got, err = pkcs1.DecryptV15(N, d, C)
+		expBool(true, err == nil)
+		expBytes(M, got)
+	}
+
+	_, err = pkcs1.EncryptV15(rand.Reader, N, e, make([]byte, k-10))
+	expBool(true, err != nil)
+
+	// Padding strings must be PS_MIN non zero octets before the separator
+	EM := make([]byte, k)
+	EM[1] = 2
+	for i := 2; i < 2+pkcs1.PS_MIN; i++ {
+		EM[i] = 0xFF
+	}
+	got, err = pkcs1.DecodeV15(k, EM)
+	expBool(true, err == nil)
+	expInt(k-pkcs1.PS_MIN-3, len(got))
+
+	EM[2+pkcs1.PS_MIN-1] = 0
+	_, err = pkcs1.DecodeV15(k, EM)
+	expBool(true, err != nil)
+
+	// Signatures made with d verify, for the digest signed only
+	h := sha256.New()
+	h.Write([]byte("attack at dawn"))
+	digest := h.Sum()
+
+	EM, err = pkcs1.EncodeSignature(digest, k)
+	expBool(true, err == nil)
+	expInt(1, int(EM[1]))
+
+	s, _ := pkcs1.RSADP(N, d, pkcs1.OS2IP(EM))
+	S, _ := pkcs1.I2OSP(s, k)
+	expBool(true, pkcs1.VerifySignature(N, e, digest, S) == nil)
+
+	digest[0] ^= 1
+	expBool(true, pkcs1.VerifySignature(N, e, digest, S) != nil)
+
+	_, err = pkcs1.EncodeSignature(make([]byte, 10), k)
+	expBool(true, err != nil)
+}
+
+func expBool(exp, got bool) {
+	if exp != got {
+		fmt.Printf("FAILED. exp=%v got=%v\n", exp, got)
+		return
+	}
+
+	fmt.Printf("PASSED.\n")
+}
+
+func expInt(exp, got int) {
+	if exp != got {
+		fmt.Printf("FAILED. exp=%d got=%d\n", exp, got)
+		return
+	}
+
+	fmt.Printf("PASSED.\n")
+}
+
+func expBytes(exp, got []byte) {
+	if !bytes.Equal(exp, got) {
+		fmt.Printf("FAILED. exp=%X got=%X\n", exp, got)
+		return
+	}
+
+	fmt.Printf("PASSED.\n")
+}
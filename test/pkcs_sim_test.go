@@ -0,0 +1,88 @@
+package main
+
+import (
+	"big"
+	"bytes"
+	"fmt"
+	"os"
+
+	"./pkcs_s"
+)
+
+var (
+	s *pkcs_s.Simulator
+)
+
+func main() {
+	var err os.Error
+
+	s, err = pkcs_s.NewSimulator(1024)
+	if err != nil {
+		panic(err)
+	}
+	k := len(s.N.Bytes())
+
+	c, err := s.Challenge([]byte{0xB6, 0x56, 0x2D, 0x3E})
+	if err != nil {
+		panic(err)
+	}
+
+	// 00 02 with a zero straight after passes only a lenient target
+	em := make([]byte, k)
+	em[1] = 2
+	short := new(big.Int).Exp(new(big.Int).SetBytes(em), s.E, s.N)
+
+	// Valid encoding
+	conforming_test(c, true, true)
+
+	// Padding string shorter than PS_MIN
+	conforming_test(short, false, true)
+
+	// 1^e = 1, no leading 00 02
+	conforming_test(big.NewInt(1), false, false)
+
+	// Responses over a stream, out of range and bad hex are input errors
+	var w bytes.Buffer
+	r := bytes.NewBufferString(fmt.Sprintf("%X\n%X\n%X\nzz\n", c, short, s.N))
+
+	s.SetStrict(true)
+	if err := s.Serve(r, &w); err != nil {
+		panic(err)
+	}
+	expString("0\n1\n2\n2\n", w.String())
+}
+
+func conforming_test(c *big.Int, strict, lenient bool) {
+	for _, mode := range []bool{true, false} {
+		s.SetStrict(mode)
+
+		ok, err := s.Conforming(c)
+		if err != nil {
+			panic(err)
+		}
+
+		exp := lenient
+		if mode {
+			exp = strict
+		}
+		expBool(exp, ok)
+	}
+}
+
+func expBool(exp, got bool) {
+	if exp != got {
+		fmt.Printf("FAILED. exp=%v got=%v\n", exp, got)
+		return
+	}
+
+	fmt.Printf("PASSED.\n")
+}
+
+func expString(exp, got string) {
+	if exp != got {
+		fmt.Printf("FAILED. exp=%q got=%q\n", exp, got)
+		return
+	}
+
+	fmt.Printf("PASSED.\n")
+}
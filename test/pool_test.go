@@ -0,0 +1,97 @@
+package main
+
+import (
+	"big"
+	"fmt"
+	"os"
+
+	"./oracle"
+	"./pool"
+	"./time_s"
+)
+
+const (
+	JOBS = 100
+	KEY  = "pool_test.key"
+)
+
+func main() {
+	defer os.Remove(KEY)
+
+	s, err := time_s.NewSimulator(512, 64)
+	if err != nil {
+		panic(err)
+	}
+
+	if err := s.WriteKey(KEY); err != nil {
+		panic(err)
+	}
+
+	// Copies of the target share the key
+	targets := make([]oracle.Target, 4)
+	for i := range targets {
+		if targets[i], err = time_s.NewSimulatorFile(KEY); err != nil {
+			panic(err)
+		}
+	}
+
+	p := pool.NewPool(targets)
+	expInt(4, p.Len())
+
+	cs := make([]*big.Int, JOBS)
+	for i := range cs {
+		cs[i] = big.NewInt(int64(i + 2))
+	}
+
+	// Results are kept in the order of the jobs
+	ms := make([][]byte, JOBS)
+	err = p.Do(JOBS, func(o oracle.Target, i int) os.Error {
+		_, m, err := o.(oracle.TimingOracle).Time(cs[i])
+		ms[i] = m
+		return err
+	})
+	expBool(true, err == nil)
+
+	ok := true
+	for i := range cs {
+		_, m, _ := s.Time(cs[i])
+		ok = ok && string(m) == string(ms[i])
+	}
+	expBool(true, ok)
+
+	// The first failing job is reported
+	err = p.Do(JOBS, func(o oracle.Target, i int) os.Error {
+		if i >= 10 {
+			return os.NewError(fmt.Sprintf("%d", i))
+		}
+		return nil
+	})
+	expString("job 10 failed: 10", fmt.Sprintf("%v", err))
+}
+
+func expBool(exp, got bool) {
+	if exp != got {
+		fmt.Printf("FAILED. exp=%v got=%v\n", exp, got)
+		return
+	}
+
+	fmt.Printf("PASSED.\n")
+}
+
+func expInt(exp, got int) {
+	if exp != got {
+		fmt.Printf("FAILED. exp=%d got=%d\n", exp, got)
+		return
+	}
+
+	fmt.Printf("PASSED.\n")
+}
+
+func expString(exp, got string) {
+	if exp != got {
+		fmt.Printf("FAILED. exp=%s got=%s\n", exp, got)
+		return
+	}
+
+	fmt.Printf("PASSED.\n")
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"bytes"
+	"crypto/aes"
+	"encoding/hex"
+	"fmt"
+	"os"
+
+	"./oracle"
+	"./power_s"
+	"./utils"
+)
+
+var (
+	s *power_s.Simulator
+)
+
+// Stand in target serving the simulator over buffers
+type Pipe struct {
+	in  bytes.Buffer
+	out bytes.Buffer
+}
+
+func (p *Pipe) Run() os.Error  { return nil }
+func (p *Pipe) Kill() os.Error { return nil }
+func (p *Pipe) Restarts() int  { return 0 }
+
+func (p *Pipe) WriteStdin(b []byte) os.Error {
+	_, err := p.in.Write(b)
+	return err
+}
+
+func (p *Pipe) ReadLines(n int) ([][]byte, os.Error) {
+	if err := s.Serve(&p.in, &p.out); err != nil {
+		return nil, err
+	}
+
+	lines := make([][]byte, n)
+	for i := range lines {
+		b, err := p.out.ReadBytes('\n')
+		if err != nil {
+			return nil, err
+		}
+		lines[i] = b[0 : len(b)-1]
+	}
+
+	return lines, nil
+}
+
+func main() {
+	var err os.Error
+
+	// IEEE 1619 XTS-AES-128 vector 1, all zero keys and plaintext
+	s, err = power_s.NewSimulatorKey(make([]byte, 16), make([]byte, 16))
+	if err != nil {
+		panic(err)
+	}
+	s.Length = 2000
+	s.Seed(1)
+
+	p := oracle.NewPowerCommand(&Pipe{}, 32)
+	zero := []byte("00000000000000000000000000000000")
+
+	s.Data = decode("917CF69EBD68B2EC9B9FE9A3EADDA692")
+	l, ss, m, err := p.Trace(0, []byte{0})
+	if err != nil {
+		panic(err)
+	}
+	expInt(s.Length, l)
+	expInt(s.Length, len(ss))
+	expBool(true, bytes.Equal(zero, m))
+
+	// The second block has the tweak multiplied by a
+	s.Data = decode("CD43D2F59598ED858C02C2652FBF922E")
+	_, _, m, err = p.Trace(1, []byte{0})
+	if err != nil {
+		panic(err)
+	}
+	expBool(true, bytes.Equal(zero, m))
+
+	// Samples parse back as the simulator made them
+	s.Seed(2)
+	_, exp, _, err := s.Trace(3, []byte{0x12, 0x34})
+	if err != nil {
+		panic(err)
+	}
+
+	s.Seed(2)
+	_, ss, _, err = p.Trace(3, []byte{0x12, 0x34})
+	if err != nil {
+		panic(err)
+	}
+	expFloats(exp, ss)
+
+	// Without noise the idle samples sit at the baseline
+	s.Noise = 0
+	_, ss, _, err = p.Trace(0, []byte{0})
+	if err != nil {
+		panic(err)
+	}
+	expBool(true, ss[0] == power_s.BASELINE && ss[len(ss)-1] == power_s.BASELINE)
+
+	// A block encrypted with AES-XTS under other keys decrypts
+	xts_test([]byte("0123456789ABCDEF"), []byte("FEDCBA9876543210"), []byte("attack at dawn!!"), []byte{0xAB, 0xCD})
+
+	_, _, _, err = s.Trace(0, make([]byte, 17))
+	expBool(true, err != nil)
+}
+
+// Encrypt m as block 0 of sector i, C = AES-k1(m ^ T) ^ T with
+// T = AES-k2(i), and check the simulator decrypts it
+func xts_test(k1, k2, m, sector []byte) {
+	var err os.Error
+
+	s, err = power_s.NewSimulatorKey(k1, k2)
+	if err != nil {
+		panic(err)
+	}
+	s.Length = 2000
+
+	aes1, err := aes.NewCipher(k1)
+	if err != nil {
+		panic(err)
+	}
+	aes2, err := aes.NewCipher(k2)
+	if err != nil {
+		panic(err)
+	}
+
+	i := make([]byte, 16)
+	copy(i[16-len(sector):], sector)
+
+	T := make([]byte, 16)
+	aes2.Encrypt(i, T)
+
+	c := make([]byte, 16)
+	aes1.Encrypt(utils.XOR(m, T), c)
+	s.Data = utils.XOR(c, T)
+
+	_, _, got, err := oracle.NewPowerCommand(&Pipe{}, 32).Trace(0, sector)
+	if err != nil {
+		panic(err)
+	}
+
+	expBool(true, bytes.Equal([]byte(fmt.Sprintf("%X", m)), got))
+}
+
+func decode(h string) []byte {
+	b := make([]byte, len(h)/2)
+	if _, err := hex.Decode(b, []byte(h)); err != nil {
+		panic(err)
+	}
+
+	return b
+}
+
+func expFloats(exp, got []float64) {
+	if len(exp) != len(got) {
+		fmt.Printf("FAILED. exp=%d samples got=%d\n", len(exp), len(got))
+		return
+	}
+
+	for i := range exp {
+		if exp[i] != got[i] {
+			fmt.Printf("FAILED. sample %d exp=%v got=%v\n", i, exp[i], got[i])
+			return
+		}
+	}
+
+	fmt.Printf("PASSED.\n")
+}
+
+func expBool(exp, got bool) {
+	if exp != got {
+		fmt.Printf("FAILED. exp=%v got=%v\n", exp, got)
+		return
+	}
+
+	fmt.Printf("PASSED.\n")
+}
+
+func expInt(exp, got int) {
+	if exp != got {
+		fmt.Printf("FAILED. exp=%d got=%d\n", exp, got)
+		return
+	}
+
+	fmt.Printf("PASSED.\n")
+}
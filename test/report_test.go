@@ -0,0 +1,66 @@
+package main
+
+import (
+	"big"
+	"bytes"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+
+	"./report"
+)
+
+func main() {
+	r := report.NewReport("oaep")
+	r.Set("material", []byte{0x0A, 0xFF})
+	r.Set("f1", big.NewInt(256))
+	r.Set("interactions", 3)
+	r.Set("correlations", []float64{0.5, math.NaN()})
+	r.Set("hypotheses", []int{1, 2})
+	r.Set("interactions", 4)
+	r.Set("materials", [][]byte{[]byte{0x0A}, nil})
+	r.Set("ems", []*big.Int{big.NewInt(1), big.NewInt(256)})
+
+	out := write(r, nil)
+	expContains(out, "\"attack\": \"oaep\"")
+	expContains(out, "\"success\": true")
+	expContains(out, "\"material\": \"0AFF\"")
+	expContains(out, "\"f1\": \"0100\"")
+	expContains(out, "\"correlations\": [0.5, null]")
+	expContains(out, "\"hypotheses\": [1, 2]")
+	expContains(out, "\"materials\": [\"0A\", null]")
+	expContains(out, "\"ems\": [\"01\", \"0100\"]")
+
+	// Setting a value again replaces it in place
+	expContains(out, "\"f1\": \"0100\",\n  \"interactions\": 4,\n  \"correlations\"")
+
+	// Failed runs carry the error
+	out = write(report.NewReport("time"), os.NewError("gave \"up\""))
+	expContains(out, "\"success\": false")
+	expContains(out, "\"error\": \"gave \\\"up\\\"\"")
+
+	// A nil report records nothing
+	var nr *report.Report
+	nr.Set("material", []byte{0})
+	fmt.Printf("PASSED.\n")
+}
+
+func write(r *report.Report, err os.Error) string {
+	var b bytes.Buffer
+
+	if err := r.Write(&b, err); err != nil {
+		panic(err)
+	}
+
+	return b.String()
+}
+
+func expContains(s, exp string) {
+	if strings.Index(s, exp) < 0 {
+		fmt.Printf("FAILED. exp=%q got=%q\n", exp, s)
+		return
+	}
+
+	fmt.Printf("PASSED.\n")
+}
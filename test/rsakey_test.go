@@ -0,0 +1,182 @@
+package main
+
+import (
+	"big"
+	"bytes"
+	crand "crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"./rsakey"
+)
+
+const (
+	PEM = "rsakey_test.pem"
+)
+
+func main() {
+	defer os.Remove(PEM)
+
+	priv, err := rsa.GenerateKey(crand.Reader, 512)
+	if err != nil {
+		panic(err)
+	}
+
+	e := big.NewInt(int64(priv.E))
+	p, q := priv.P, priv.Q
+	if p.Cmp(q) > 0 {
+		p, q = q, p
+	}
+
+	// N factored from d alone
+	key, err := rsakey.NewKey(priv.N, e, priv.D)
+	expErr(nil, err)
+	if key == nil {
+		return
+	}
+	expString(p.String(), key.P.String())
+	expString(q.String(), key.Q.String())
+	expString(priv.N.String(), key.N.String())
+	expString(priv.D.String(), key.D.String())
+
+	// CRT values agree with signing
+	m := big.NewInt(0x5EED)
+	sp := new(big.Int).Exp(m, key.DP, key.P)
+	sq := new(big.Int).Exp(m, key.DQ, key.Q)
+	h := new(big.Int).Sub(sp, sq)
+	h.Mul(h, key.QInv)
+	h.Mod(h, key.P)
+	h.Mul(h, key.Q).Add(h, sq)
+	expString(new(big.Int).Exp(m, priv.D, priv.N).String(), h.String())
+
+	// PKCS#1 DER reads back as the same key
+	parsed, err := x509.ParsePKCS1PrivateKey(key.PKCS1())
+	expErr(nil, err)
+	if parsed != nil {
+		expString(key.D.String(), parsed.D.String())
+		expString(priv.N.String(), parsed.N.String())
+	}
+
+	// PKCS#8 is a version 0 PrivateKeyInfo of an rsaEncryption key with
+	// NULL parameters, wrapping the PKCS#1 key
+	tag, info, rest := tlv(key.PKCS8())
+	expBool(true, tag == 0x30 && len(rest) == 0)
+
+	tag, version, info := tlv(info)
+	expBool(true, tag == 0x02 && bytes.Equal([]byte{0}, version))
+
+	tag, alg, info := tlv(info)
+	expBool(true, tag == 0x30)
+	tag, id, alg := tlv(alg)
+	expString("1.2.840.113549.1.1.1", oid(id))
+	tag, params, alg := tlv(alg)
+	expBool(true, tag == 0x05 && len(params) == 0 && len(alg) == 0)
+
+	tag, pkcs1, info := tlv(info)
+	expBool(true, tag == 0x04 && len(info) == 0)
+	expInt(len(key.PKCS1()), len(pkcs1))
+	expBool(true, bytes.Equal(key.PKCS1(), pkcs1))
+
+	// Key files are readable by the owner only, even when overwritten
+	if err := ioutil.WriteFile(PEM, nil, 0644); err != nil {
+		panic(err)
+	}
+	expErr(nil, key.WritePEM(PEM, false))
+	fi, err := os.Stat(PEM)
+	if err != nil {
+		panic(err)
+	}
+	expBool(true, fi.Permission() == 0600)
+
+	// d of another e does not factor N
+	_, err = rsakey.NewKey(priv.N, big.NewInt(3), priv.D)
+	expBool(true, err != nil)
+}
+
+// Split the DER tag and value from the front of b. A truncated b gives
+// tag 0.
+func tlv(b []byte) (byte, []byte, []byte) {
+	if len(b) < 2 {
+		return 0, nil, nil
+	}
+	tag, l, b := b[0], int(b[1]), b[2:]
+
+	// Long form lengths give the number of length octets
+	if l&0x80 != 0 {
+		n := l & 0x7F
+		if n > len(b) {
+			return 0, nil, nil
+		}
+
+		l = 0
+		for _, x := range b[0:n] {
+			l = l<<8 | int(x)
+		}
+		b = b[n:]
+	}
+
+	if l > len(b) {
+		return 0, nil, nil
+	}
+
+	return tag, b[0:l], b[l:]
+}
+
+// Dotted form of the DER object identifier b
+func oid(b []byte) string {
+	if len(b) == 0 {
+		return ""
+	}
+
+	s := fmt.Sprintf("%d.%d", b[0]/40, b[0]%40)
+
+	x := 0
+	for _, c := range b[1:] {
+		x = x<<7 | int(c&0x7F)
+		if c&0x80 == 0 {
+			s += fmt.Sprintf(".%d", x)
+			x = 0
+		}
+	}
+
+	return s
+}
+
+func expErr(exp, got os.Error) {
+	if exp != got {
+		fmt.Printf("FAILED. exp=%v got=%v\n", exp, got)
+		return
+	}
+
+	fmt.Printf("PASSED.\n")
+}
+
+func expString(exp, got string) {
+	if exp != got {
+		fmt.Printf("FAILED. exp=%s got=%s\n", exp, got)
+		return
+	}
+
+	fmt.Printf("PASSED.\n")
+}
+
+func expBool(exp, got bool) {
+	if exp != got {
+		fmt.Printf("FAILED. exp=%v got=%v\n", exp, got)
+		return
+	}
+
+	fmt.Printf("PASSED.\n")
+}
+
+func expInt(exp, got int) {
+	if exp != got {
+		fmt.Printf("FAILED. exp=%d got=%d\n", exp, got)
+		return
+	}
+
+	fmt.Printf("PASSED.\n")
+}
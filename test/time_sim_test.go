@@ -0,0 +1,56 @@
+package main
+
+import (
+	"big"
+	"fmt"
+
+	"./montgomery"
+	"./time_s"
+)
+
+func main() {
+	s, err := time_s.NewSimulator(512, 64)
+	if err != nil {
+		panic(err)
+	}
+	s.Seed(1)
+
+	m := big.NewInt(12345)
+	c := new(big.Int).Exp(m, s.E, s.N)
+
+	// Decrypting returns the message in hex
+	_, got, err := s.Time(c)
+	if err != nil {
+		panic(err)
+	}
+	expString(fmt.Sprintf("%X", m.Bytes()), string(got))
+
+	// Without noise equal ciphertexts take equal time
+	t1, _, _ := s.Time(c)
+	t2, _, _ := s.Time(c)
+	expInt(0, t1.Cmp(t2))
+
+	// Windowed and square and multiply exponentiation agree
+	mnt := montgomery.NewMontgomery(s.N)
+	r1, _ := mnt.ExpRed(c, s.E, 1)
+	r6, _ := mnt.ExpRed(c, s.E, 6)
+	expInt(0, r1.Cmp(r6))
+}
+
+func expString(exp, got string) {
+	if exp != got {
+		fmt.Printf("FAILED. exp=%s got=%s\n", exp, got)
+		return
+	}
+
+	fmt.Printf("PASSED.\n")
+}
+
+func expInt(exp, got int) {
+	if exp != got {
+		fmt.Printf("FAILED. exp=%d got=%d\n", exp, got)
+		return
+	}
+
+	fmt.Printf("PASSED.\n")
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"big"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"./oracle"
+	"./transcript"
+	"./utils"
+)
+
+const (
+	FILE = "transcript_test.txt"
+)
+
+// Stand in target echoing the first byte of its last write
+type Echo struct {
+	last []byte
+}
+
+func (e *Echo) Run() os.Error  { return nil }
+func (e *Echo) Kill() os.Error { return nil }
+func (e *Echo) Restarts() int  { return 0 }
+
+func (e *Echo) WriteStdin(b []byte) os.Error {
+	e.last = b
+	return nil
+}
+
+func (e *Echo) ReadLines(n int) ([][]byte, os.Error) {
+	return [][]byte{e.last[0:1]}, nil
+}
+
+func main() {
+	defer os.Remove(FILE)
+
+	r, err := transcript.NewRecorder(&Echo{}, FILE, 1)
+	if err != nil {
+		panic(err)
+	}
+
+	utils.Seed(1)
+	exp := interact(oracle.NewDecryptCommand(r, 256), 3)
+	if err := r.Kill(); err != nil {
+		panic(err)
+	}
+
+	// Replaying with the same seed gives back the same responses
+	p, err := transcript.NewPlayer(FILE)
+	if err != nil {
+		panic(err)
+	}
+
+	utils.Seed(1)
+	d := oracle.NewDecryptCommand(p, 256)
+	got := interact(d, 3)
+	for i := range exp {
+		expByte(exp[i], got[i])
+	}
+
+	// Nothing left to replay
+	_, err = d.Decrypt([]byte("00\n"), big.NewInt(1))
+	expBool(true, err != nil)
+
+	// A different request diverges from the transcript
+	p, err = transcript.NewPlayer(FILE)
+	if err != nil {
+		panic(err)
+	}
+
+	utils.Seed(2)
+	_, err = oracle.NewDecryptCommand(p, 256).Decrypt([]byte("00\n"), utils.RandInt(2, 64))
+	expBool(true, err != nil)
+
+	// Asking for more lines than were recorded
+	if err := ioutil.WriteFile(FILE, []byte("0 1 > 41\n0 1 < 42\n"), 0644); err != nil {
+		panic(err)
+	}
+
+	p, err = transcript.NewPlayer(FILE)
+	if err != nil {
+		panic(err)
+	}
+
+	if err := p.WriteStdin([]byte("A")); err != nil {
+		panic(err)
+	}
+
+	_, err = p.ReadLines(2)
+	expBool(true, err != nil && strings.Contains(err.String(), "exp=2 lines got=1"))
+}
+
+func interact(d oracle.DecryptOracle, n int) []byte {
+	codes := make([]byte, n)
+
+	for i := range codes {
+		c, err := d.Decrypt([]byte("00\n"), utils.RandInt(2, 64))
+		if err != nil {
+			panic(err)
+		}
+		codes[i] = c
+	}
+
+	return codes
+}
+
+func expBool(exp, got bool) {
+	if exp != got {
+		fmt.Printf("FAILED. exp=%v got=%v\n", exp, got)
+		return
+	}
+
+	fmt.Printf("PASSED.\n")
+}
+
+func expByte(exp, got byte) {
+	if exp != got {
+		fmt.Printf("FAILED. exp=%c got=%c\n", exp, got)
+		return
+	}
+
+	fmt.Printf("PASSED.\n")
+}
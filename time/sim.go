@@ -0,0 +1,80 @@
+///////////////////////////////////////////////////////////
+//                                                       //
+//                 Joshua Van Leeuwen                    //
+//                                                       //
+//                University of Bristol                  //
+//                                                       //
+///////////////////////////////////////////////////////////
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"./time_s"
+	"./utils"
+)
+
+var (
+	gen    = flag.Bool("gen", false, "generate a new key and conf, then exit")
+	bits   = flag.Int("bits", 1024, "modulus size in bits of a generated key")
+	dbits  = flag.Int("dbits", 64, "private exponent size in bits of a generated key")
+	key    = flag.String("key", "", "key file of hex lines N, e and d (default <binary>.key)")
+	conf   = flag.String("conf", "sim.conf", "conf file written with -gen")
+	noise  = flag.Float64("noise", 0, "standard deviation in cycles of the timing noise")
+	window = flag.Int("window", 1, "sliding window size of the exponentiation")
+	seed   = flag.Int64("seed", 0, "seed of the timing noise (default time based)")
+)
+
+func main() {
+	flag.Parse()
+
+	keyFile := *key
+	if keyFile == "" {
+		keyFile = fmt.Sprintf("%s.key", os.Args[0])
+	}
+
+	if *gen {
+		if err := generate(keyFile); err != nil {
+			utils.Fatal(err)
+		}
+		return
+	}
+
+	s, err := time_s.NewSimulatorFile(keyFile)
+	if err != nil {
+		utils.Fatal(err)
+	}
+
+	s.Noise = *noise
+	s.Window = *window
+	if *seed != 0 {
+		s.Seed(*seed)
+	}
+
+	if err := s.Serve(os.Stdin, os.Stdout); err != nil {
+		utils.Fatal(err)
+	}
+}
+
+// Generate a key and a conf of its public half
+func generate(keyFile string) os.Error {
+	s, err := time_s.NewSimulator(*bits, *dbits)
+	if err != nil {
+		return err
+	}
+
+	if err := s.WriteKey(keyFile); err != nil {
+		return err
+	}
+
+	if err := s.WriteConf(*conf); err != nil {
+		return err
+	}
+
+	fmt.Printf("Key: %s\nConf: %s\n", keyFile, *conf)
+
+	return nil
+}
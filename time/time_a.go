@@ -0,0 +1,612 @@
+///////////////////////////////////////////////////////////
+//                                                       //
+//                 Joshua Van Leeuwen                    //
+//                                                       //
+//                University of Bristol                  //
+//                                                       //
+///////////////////////////////////////////////////////////
+
+package time_a
+
+import (
+	"big"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"./file"
+	"./montgomery"
+	"./oracle"
+	"./pool"
+	"./report"
+	"./rsakey"
+	"./time_c"
+	"./transcript"
+	"./utils"
+)
+
+const (
+	WORD_LENGTH = 256
+
+	// Sets of samples to try before giving up
+	SETS = 30
+	// Bits recovered between checkpoints
+	CHECKPOINT_BITS = 16
+)
+
+var (
+	initSamples *int
+	threshold   *float64
+	poolSize    *int
+	checkpoint  *string
+	resume      *bool
+	pemFile     *string
+	pkcs8       *bool
+)
+
+type Attack struct {
+	pool   *pool.Pool
+	conf   *time_c.Conf
+	report *report.Report
+
+	interactions int
+	mu           sync.Mutex
+
+	initSamples int
+	threshold   float64
+
+	samples *Samples
+	mnt     *montgomery.Montgomery
+
+	// Recovery state, saved to checkpoint every CHECKPOINT_BITS bits
+	set        int
+	samplesN   int
+	bits       string
+	diffs      []float64
+	checkpoint string
+
+	// PEM file the reconstructed key is written to, if any
+	pemFile string
+	pkcs8   bool
+
+	bit0_reds []float64
+	bit1_reds []float64
+	tList0    []*big.Int
+	tList1    []*big.Int
+}
+
+type Samples struct {
+	xList    []*big.Int
+	tList    []*big.Int
+	fTList   []float64
+	messages []*big.Int
+}
+
+// Register the attack flags
+func Flags() {
+	initSamples = flag.Int("samples", 2000, "number of timing samples to start with")
+	threshold = flag.Float64("threshold", 0.01, "correlation below which a set of samples is given up on")
+	poolSize = flag.Int("pool", 1, "number of copies of the target to gather samples from")
+	checkpoint = flag.String("checkpoint", "", "file to save samples and recovered bits to as the attack runs")
+	resume = flag.Bool("resume", false, "continue from the -checkpoint file without gathering its samples again")
+	pemFile = flag.String("pem", "", "file to write the reconstructed private key to as PEM")
+	pkcs8 = flag.Bool("pkcs8", false, "write the -pem key as PKCS#8 rather than PKCS#1")
+}
+
+// Run the attack on the target and conf file in args, recording the
+// results in r
+func Main(args []string, r *report.Report) os.Error {
+	fmt.Printf("Initalising attack...")
+	a, err := NewAttack(args, *initSamples, *threshold, *poolSize)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("done.\n")
+
+	if *resume && *checkpoint == "" {
+		return utils.NewError("-resume needs a -checkpoint file")
+	}
+
+	runtime.GOMAXPROCS(2)
+
+	a.report = r
+	a.SetCheckpoint(*checkpoint)
+	a.pemFile = *pemFile
+	a.pkcs8 = *pkcs8
+	err = a.Run(*resume)
+
+	r.Set("interactions", a.interactions)
+	r.Set("restarts", a.pool.Restarts())
+
+	return err
+}
+
+// Initialise new attack struct, gathering samples from n copies of the
+// target
+func NewAttack(args []string, samples int, threshold float64, n int) (attack *Attack, err os.Error) {
+	if samples < 1 || n < 1 {
+		return nil, utils.NewError(fmt.Sprintf("expected at least 1 sample and target, got samples=%d pool=%d", samples, n))
+	}
+
+	conf, err := time_c.NewConf(args[1])
+	if err != nil {
+		return nil, err
+	}
+
+	cmds, err := transcript.NewProcesses(args[0], n)
+	if err != nil {
+		return nil, err
+	}
+
+	oracles := make([]oracle.Target, n)
+	for i := range oracles {
+		oracles[i] = oracle.NewTimingCommand(cmds[i], WORD_LENGTH)
+	}
+
+	return &Attack{
+			conf:         conf,
+			pool:         pool.NewPool(oracles),
+			interactions: 0,
+			mnt:          montgomery.NewMontgomery(conf.N),
+			initSamples:  samples,
+			threshold:    threshold,
+		},
+		nil
+}
+
+// Main attack run function, resuming from the checkpoint file if resume
+// is set
+func (a *Attack) Run(resume bool) os.Error {
+	fmt.Printf("Executing Attack.\n")
+
+	if err := a.pool.Run(); err != nil {
+		return err
+	}
+
+	if resume {
+		fmt.Printf("Resuming from '%s'...", a.checkpoint)
+		if err := a.LoadCheckpoint(); err != nil {
+			return utils.Error("failed to resume", err)
+		}
+		fmt.Printf("done.\n")
+	} else if err := a.generateSamples(a.initSamples); err != nil {
+		return utils.Error("failed to generate samples", err)
+	}
+
+	now := time.Nanoseconds()
+
+	fmt.Printf("Finding key...\n")
+	d, err := a.findKey()
+	if err != nil {
+		return utils.Error("error finding key", err)
+	}
+	fmt.Printf("\nKey found and tested.\n")
+
+	fmt.Printf("Attack Complete.\n")
+	fmt.Printf("Elapsed time: %.2fs\n*********\n", float((time.Nanoseconds()-now))/1e9)
+
+	fmt.Printf("Target material: [%X]\n", d.Bytes())
+	a.report.Set("material", d)
+
+	// d is found, so failing to reconstruct or write the key is not fatal
+	if err := a.writeKey(d); err != nil {
+		fmt.Printf("Warning: %s\n", err.String())
+		a.report.Set("warning", err.String())
+	}
+	fmt.Printf("Interactions: %d\n", a.interactions)
+	fmt.Printf("Restarts: %d\n", a.pool.Restarts())
+
+	return nil
+}
+
+// Reconstruct the key from d, printing its factors and writing it as PEM
+func (a *Attack) writeKey(d *big.Int) os.Error {
+	key, err := rsakey.NewKey(a.conf.N, a.conf.E, d)
+	if err != nil {
+		return utils.Error("failed to reconstruct key", err)
+	}
+
+	fmt.Printf("P: [%X]\n", key.P.Bytes())
+	fmt.Printf("Q: [%X]\n", key.Q.Bytes())
+	a.report.Set("p", key.P)
+	a.report.Set("q", key.Q)
+
+	if a.pemFile != "" {
+		if err := key.WritePEM(a.pemFile, a.pkcs8); err != nil {
+			return err
+		}
+		fmt.Printf("Key: %s\n", a.pemFile)
+		a.report.Set("pem", a.pemFile)
+	}
+
+	return nil
+}
+
+// Function to loop over trying samples
+func (a *Attack) findKey() (*big.Int, os.Error) {
+	for {
+		if a.trySamples() {
+			a.report.Set("samples", a.samplesN)
+			a.report.Set("correlations", a.diffs)
+			return utils.BinaryStringToInt(a.bits), nil
+		}
+
+		fmt.Printf("\nFailed to find key with this set.\n")
+
+		a.set++
+		if a.set >= SETS {
+			break
+		}
+
+		if err := a.generateSamples(a.samplesN + 1000); err != nil {
+			return nil, err
+		}
+	}
+
+	return nil, utils.NewError(fmt.Sprintf("failed after %d sets of samples, giving up.", SETS))
+}
+
+// Generate random samples for use in attack
+func (a *Attack) generateSamples(samplesN int) os.Error {
+
+	fmt.Printf("Generating samples [%d]...", samplesN)
+
+	samples := new(Samples)
+	t, _ := a.mnt.Mul(big.NewInt(1), a.mnt.Ro2)
+	t, _ = a.mnt.Mul(t, t)
+
+	// Ciphertexts are chosen up front so they do not depend on the order
+	// the pool answers in
+	cs := make([]*big.Int, samplesN)
+	for i := range cs {
+		cs[i] = utils.RandInt(16, 128)
+	}
+
+	ts := make([]*big.Int, samplesN)
+	err := a.pool.Do(samplesN, func(o oracle.Target, i int) os.Error {
+		_, tt, err := a.Interact(o.(oracle.TimingOracle), cs[i])
+		ts[i] = tt
+		return err
+	})
+	if err != nil {
+		return utils.Error("error interacting for samples", err)
+	}
+
+	for i, c := range cs {
+		samples.fTList = utils.AppendFloat(samples.fTList, utils.BigIntToFloat(ts[i]))
+
+		x, _ := a.mnt.Mul(c, a.mnt.Ro2)
+		samples.xList = utils.AppendBigInt(samples.xList, x)
+
+		tmp, _ := a.mnt.Mul(t, x)
+		samples.tList = utils.AppendBigInt(samples.tList, tmp)
+	}
+
+	a.samples = samples
+	a.samplesN = samplesN
+	a.bits = "1"
+	a.diffs = nil
+
+	fmt.Printf("done.\n")
+
+	if err := a.SaveCheckpoint(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Calculate bits with samples, carrying on from the bits recovered so far
+func (a *Attack) trySamples() (found bool) {
+	var diff float64
+
+	samplesN := len(a.samples.xList)
+	kSize := len(a.bits)
+
+	test_message := big.NewInt(12345)
+	test_cipher := new(big.Int).Exp(test_message, a.conf.E, a.conf.N)
+
+	a.printProgess(kSize, 0, a.bits)
+
+	a.bit0_reds = make([]float64, samplesN)
+	a.bit1_reds = make([]float64, samplesN)
+
+	a.tList0 = make([]*big.Int, samplesN)
+	a.tList1 = make([]*big.Int, samplesN)
+
+	for {
+		kSize++
+
+		wg := utils.NewWaitGroup(samplesN)
+
+		for i := 0; i < samplesN; i++ {
+			go a.compute(i, wg)
+		}
+
+		runtime.Gosched()
+		go wg.Wait()
+
+		// Calculate difference using correlation
+		diff0 := a.correlation(a.bit0_reds)
+		diff1 := a.correlation(a.bit1_reds)
+
+		if diff0 > diff1 {
+			a.bits = fmt.Sprintf("%s0", a.bits)
+			a.printProgess(kSize, diff0, a.bits)
+			a.samples.tList = a.tList0
+			diff = diff0
+		} else {
+			a.bits = fmt.Sprintf("%s1", a.bits)
+			a.printProgess(kSize, diff1, a.bits)
+			a.samples.tList = a.tList1
+			diff = diff1
+		}
+		a.diffs = utils.AppendFloat(a.diffs, diff)
+
+		k1 := utils.BinaryStringToInt(fmt.Sprintf("%s1", a.bits))
+		if new(big.Int).Exp(test_cipher, k1, a.conf.N).Cmp(test_message) == 0 {
+			a.bits = fmt.Sprintf("%s1", a.bits)
+			found = true
+			break
+		}
+
+		k0 := utils.BinaryStringToInt(fmt.Sprintf("%s0", a.bits))
+		if new(big.Int).Exp(test_cipher, k0, a.conf.N).Cmp(test_message) == 0 {
+			a.bits = fmt.Sprintf("%s0", a.bits)
+			found = true
+			break
+		}
+
+		if diff < a.threshold {
+			found = false
+			break
+		}
+
+		if len(a.diffs)%CHECKPOINT_BITS == 0 {
+			if err := a.SaveCheckpoint(); err != nil {
+				fmt.Printf("\n%v\n", err)
+			}
+		}
+	}
+
+	return found
+}
+
+// Compute the Montgomery multiplications with captured reductions
+func (a *Attack) compute(i int, wg *utils.WaitGroup) {
+
+	a.tList0[i], _ = a.mnt.Mul(a.samples.tList[i], a.samples.tList[i])
+	a.tList1[i], _ = a.mnt.Mul(a.tList0[i], a.samples.xList[i])
+
+	_, red0 := a.mnt.Mul(a.tList0[i], a.tList0[i])
+	_, red1 := a.mnt.Mul(a.tList1[i], a.tList1[i])
+
+	if red0 {
+		a.bit0_reds[i] = 1
+	} else {
+		a.bit0_reds[i] = 0
+	}
+
+	if red1 {
+		a.bit1_reds[i] = 1
+	} else {
+		a.bit1_reds[i] = 0
+	}
+
+	wg.Done()
+
+	runtime.Goexit()
+}
+
+// Calculate the correlation of reductions and times
+func (a *Attack) correlation(reds []float64) float64 {
+	var R float64
+
+	MM := make([]float64, len(reds))
+	TT := make([]float64, len(reds))
+
+	EM := utils.AverageFloat(reds)
+	ET := utils.AverageFloat(a.samples.fTList)
+
+	for i := range reds {
+		R += (reds[i] - EM) * (a.samples.fTList[i] - ET)
+	}
+
+	R = R / float64(len(reds))
+
+	for i := range reds {
+		MM[i] = math.Pow(reds[i]-EM, 2)
+		TT[i] = math.Pow(a.samples.fTList[i]-ET, 2)
+	}
+
+	varM := utils.AverageFloat(MM)
+	varT := utils.AverageFloat(TT)
+
+	return R / math.Sqrt(varM*varT)
+}
+
+// Pretty print the progress of key bits
+func (a *Attack) printProgess(size int, diff float64, k string) {
+	star := k
+	for i := len(k); i < 59; i++ {
+		star += "*"
+	}
+
+	fmt.Printf("\r(%.2d) [%s] diff(%.3f) ", size, star, diff)
+}
+
+// Interact with D through a timing oracle of the pool
+func (a *Attack) Interact(o oracle.TimingOracle, c *big.Int) (m []byte, t *big.Int, err os.Error) {
+	t, m, err = o.Time(c)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	a.mu.Lock()
+	a.interactions++
+	a.mu.Unlock()
+
+	return m, t, nil
+}
+
+// Set the file samples and recovered bits are checkpointed to
+func (a *Attack) SetCheckpoint(fileName string) { a.checkpoint = fileName }
+
+// Write the samples and recovered bits to the checkpoint file, if any.
+// The file is written aside and renamed so a crash leaves the last one.
+func (a *Attack) SaveCheckpoint() os.Error {
+	if a.checkpoint == "" {
+		return nil
+	}
+
+	tmp := a.checkpoint + ".tmp"
+	fw, err := file.NewFileWriter(tmp)
+	if err != nil {
+		return err
+	}
+
+	diffs := make([]string, len(a.diffs))
+	for i, diff := range a.diffs {
+		diffs[i] = strconv.Ftoa64(diff, 'g', -1)
+	}
+
+	lines := []string{
+		"# time attack checkpoint, one 'x t time' line per sample",
+		fmt.Sprintf("N = %X", a.conf.N.Bytes()),
+		fmt.Sprintf("set = %d", a.set),
+		fmt.Sprintf("interactions = %d", a.interactions),
+		fmt.Sprintf("bits = %s", a.bits),
+		fmt.Sprintf("diffs = %s", strings.Join(diffs, " ")),
+	}
+
+	for i := range a.samples.xList {
+		lines = utils.Append(lines, fmt.Sprintf("%X %X %s", a.samples.xList[i].Bytes(), a.samples.tList[i].Bytes(), strconv.Ftoa64(a.samples.fTList[i], 'g', -1)))
+	}
+
+	for _, line := range lines {
+		if err := fw.WriteLine([]byte(line)); err != nil {
+			return err
+		}
+	}
+
+	if err := fw.CloseFile(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmp, a.checkpoint); err != nil {
+		return utils.Error(fmt.Sprintf("failed to write checkpoint '%s'", a.checkpoint), err)
+	}
+
+	return nil
+}
+
+// Read the samples and recovered bits back from the checkpoint file
+func (a *Attack) LoadCheckpoint() os.Error {
+	fr, err := file.NewFileReader(a.checkpoint)
+	if err != nil {
+		return err
+	}
+
+	lines, err := fr.ReadLines()
+	if err != nil {
+		return err
+	}
+
+	if err := fr.CloseFile(); err != nil {
+		return err
+	}
+
+	samples := new(Samples)
+	fields := make(map[string]string)
+
+	for i, b := range lines {
+		line := strings.TrimSpace(string(b))
+		if len(line) == 0 || line[0] == '#' {
+			continue
+		}
+
+		if k := strings.Index(line, "="); k >= 0 {
+			fields[strings.TrimSpace(line[0:k])] = strings.TrimSpace(line[k+1:])
+			continue
+		}
+
+		x, t, fT, ok := parseSample(line)
+		if !ok {
+			return utils.NewError(fmt.Sprintf("checkpoint '%s' line %d: expected 'x t time', got '%s'", a.checkpoint, i+1, line))
+		}
+
+		samples.xList = utils.AppendBigInt(samples.xList, x)
+		samples.tList = utils.AppendBigInt(samples.tList, t)
+		samples.fTList = utils.AppendFloat(samples.fTList, fT)
+	}
+
+	if fields["N"] != fmt.Sprintf("%X", a.conf.N.Bytes()) {
+		return utils.NewError(fmt.Sprintf("checkpoint '%s' is for a different N", a.checkpoint))
+	}
+
+	bits := fields["bits"]
+	if len(bits) == 0 || strings.Trim(bits, "01") != "" {
+		return utils.NewError(fmt.Sprintf("checkpoint '%s': expected binary bits, got '%s'", a.checkpoint, bits))
+	}
+
+	if len(samples.xList) == 0 {
+		return utils.NewError(fmt.Sprintf("checkpoint '%s' has no samples", a.checkpoint))
+	}
+
+	set, err := strconv.Atoi(fields["set"])
+	if err != nil {
+		return utils.Error(fmt.Sprintf("checkpoint '%s': bad set", a.checkpoint), err)
+	}
+
+	interactions, err := strconv.Atoi(fields["interactions"])
+	if err != nil {
+		return utils.Error(fmt.Sprintf("checkpoint '%s': bad interactions", a.checkpoint), err)
+	}
+
+	var diffs []float64
+	for _, s := range strings.Fields(fields["diffs"]) {
+		diff, err := strconv.Atof64(s)
+		if err != nil {
+			return utils.Error(fmt.Sprintf("checkpoint '%s': bad diffs", a.checkpoint), err)
+		}
+		diffs = utils.AppendFloat(diffs, diff)
+	}
+
+	a.samples = samples
+	a.samplesN = len(samples.xList)
+	a.set = set
+	a.interactions = interactions
+	a.bits = bits
+	a.diffs = diffs
+
+	return nil
+}
+
+// Parse a checkpoint sample line of hex x and t and the float time
+func parseSample(line string) (x, t *big.Int, fT float64, ok bool) {
+	fs := strings.Fields(line)
+	if len(fs) != 3 {
+		return nil, nil, 0, false
+	}
+
+	if x, ok = new(big.Int).SetString(fs[0], 16); !ok {
+		return nil, nil, 0, false
+	}
+
+	if t, ok = new(big.Int).SetString(fs[1], 16); !ok {
+		return nil, nil, 0, false
+	}
+
+	fT, err := strconv.Atof64(fs[2])
+	if err != nil {
+		return nil, nil, 0, false
+	}
+
+	return x, t, fT, true
+}